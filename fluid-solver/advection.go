@@ -0,0 +1,93 @@
+package fluid
+
+import "math"
+
+// AdvectionScheme defines how the fields are moved along the velocity field.
+type AdvectionScheme int
+
+const (
+	// AdvectionSemiLagrangian is the first order semi-Lagrangian scheme with bilinear interpolation.
+	AdvectionSemiLagrangian AdvectionScheme = iota
+	// AdvectionMacCormack corrects the semi-Lagrangian step with the error of a backward step.
+	AdvectionMacCormack
+	// AdvectionBFECC compensates the error of a forward and backward step before advecting.
+	AdvectionBFECC
+)
+
+// advectMacCormack advects {d0} with the MacCormack scheme. The error of the semi-Lagrangian step
+// is estimated by tracing its result back in time, and half of it is added to the result.
+func (fs *Solver) advectMacCormack(bound BoundaryType, d, d0, u, v cell) {
+	fwd, bwd := fs.advectionBuffers()
+
+	fs.semiLagrangian(fwd, d0, u, v, fs.dt)
+	fs.setBoundary(bound, fwd)
+	fs.semiLagrangian(bwd, fwd, u, v, -fs.dt)
+
+	fs.parallel(func(j0, j1 int) {
+		for j := j0; j <= j1; j++ {
+			for i := 1; i <= fs.nx; i++ {
+				k := fs.idx(i, j)
+				d[k] = fwd[k] + 0.5*(d0[k]-bwd[k])
+			}
+		}
+	})
+	fs.limit(d, d0, fwd, u, v)
+}
+
+// advectBFECC advects {d0} with the Back and Forth Error Compensation and Correction scheme.
+// The error of a forward and backward semi-Lagrangian step is removed from {d0} before
+// the final semi-Lagrangian step.
+func (fs *Solver) advectBFECC(bound BoundaryType, d, d0, u, v cell) {
+	fwd, bwd := fs.advectionBuffers()
+
+	fs.semiLagrangian(fwd, d0, u, v, fs.dt)
+	fs.setBoundary(bound, fwd)
+	fs.semiLagrangian(bwd, fwd, u, v, -fs.dt)
+
+	fs.parallel(func(j0, j1 int) {
+		for j := j0; j <= j1; j++ {
+			for i := 1; i <= fs.nx; i++ {
+				k := fs.idx(i, j)
+				bwd[k] = d0[k] + 0.5*(d0[k]-bwd[k])
+			}
+		}
+	})
+	fs.setBoundary(bound, bwd)
+	fs.semiLagrangian(d, bwd, u, v, fs.dt)
+	fs.limit(d, d0, fwd, u, v)
+}
+
+// limit keeps the scheme stable by falling back to the semi-Lagrangian result {fwd} wherever {d}
+// leaves the range of the four {d0} values the semi-Lagrangian step has interpolated between.
+func (fs *Solver) limit(d, d0, fwd, u, v cell) {
+	fs.parallel(func(j0, j1 int) {
+		for j := j0; j <= j1; j++ {
+			for i := 1; i <= fs.nx; i++ {
+				k := fs.idx(i, j)
+				if fs.isSolid(k) {
+					continue
+				}
+				x, y := fs.backtrace(i, j, u, v, fs.dt)
+				i0, k0 := int(x), int(y)
+
+				a, b := d0[fs.idx(i0, k0)], d0[fs.idx(i0+1, k0)]
+				c, e := d0[fs.idx(i0, k0+1)], d0[fs.idx(i0+1, k0+1)]
+				min := math.Min(math.Min(a, b), math.Min(c, e))
+				max := math.Max(math.Max(a, b), math.Max(c, e))
+
+				if d[k] < min || d[k] > max {
+					d[k] = fwd[k]
+				}
+			}
+		}
+	})
+}
+
+// advectionBuffers returns the scratch fields of the higher order advection schemes.
+func (fs *Solver) advectionBuffers() (cell, cell) {
+	if len(fs.advFwd) != fs.numOfCells {
+		fs.advFwd = make(cell, fs.numOfCells)
+		fs.advBwd = make(cell, fs.numOfCells)
+	}
+	return fs.advFwd, fs.advBwd
+}
@@ -0,0 +1,161 @@
+package fluid
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrInvalidConfig is returned when the solver configuration is not valid.
+var ErrInvalidConfig = errors.New("fluid: invalid config")
+
+// SolverConfig holds the tunable parameters of the fluid solver.
+type SolverConfig struct {
+	// Dt is the time step of the simulation.
+	Dt float64
+	// CFL is the maximum number of cells the fluid may travel in a substep of Step.
+	CFL float64
+	// MaxSubsteps limits the number of the substeps a single Step is split into.
+	MaxSubsteps int
+	// Diffusion is the density diffusion rate.
+	Diffusion float64
+	// Viscosity is the velocity diffusion rate.
+	Viscosity float64
+	// Iterations is the number of the linear solver iterations.
+	Iterations int
+	// Advection is the scheme moving the fields along the velocity field.
+	Advection AdvectionScheme
+	// Workers is the number of goroutines sharing the grid rows.
+	// Values below 2 run the solver on the calling goroutine.
+	Workers int
+
+	// Vorticity enables the vorticity confinement.
+	Vorticity bool
+	// VorticityStrength scales the vorticity confinement force.
+	VorticityStrength float64
+
+	// Buoyancy enables the buoyancy force.
+	Buoyancy bool
+	// BuoyancyWeight is the downward force coefficient of the smoke density.
+	BuoyancyWeight float64
+	// BuoyancyLift is the upward force coefficient of the difference from the ambient temperature.
+	BuoyancyLift float64
+
+	// GravityX and GravityY are the components of the uniform acceleration of the whole fluid,
+	// like the gravity or a steady wind. Between closed walls it's balanced by the pressure,
+	// so it only moves the fluid through the open or periodic edges.
+	GravityX, GravityY float64
+
+	// AmbientTemperature is the temperature of the surrounding air.
+	AmbientTemperature float64
+	// TemperatureDiffusion is the heat diffusion rate.
+	TemperatureDiffusion float64
+	// Cooling is the rate the temperature approaches the ambient temperature.
+	Cooling float64
+
+	// DyeChannels is the number of the colored dye fields carried by the fluid.
+	DyeChannels int
+
+	// Edges defines the boundary conditions of the grid edges. The zero value means solid walls.
+	Edges Edges
+}
+
+// DefaultConfig returns the default solver configuration.
+func DefaultConfig() SolverConfig {
+	return SolverConfig{
+		Dt:                0.2,
+		CFL:               5,
+		MaxSubsteps:       8,
+		Diffusion:         0.0001,
+		Viscosity:         0.0,
+		Iterations:        10,
+		Advection:         AdvectionSemiLagrangian,
+		Workers:           1,
+		Vorticity:         true,
+		VorticityStrength: 1.0,
+		Buoyancy:          true,
+		BuoyancyWeight:    0.000625,
+		BuoyancyLift:      0.015,
+
+		AmbientTemperature:   0,
+		TemperatureDiffusion: 0.0001,
+		Cooling:              0.05,
+	}
+}
+
+// Validate checks if the configuration values are usable by the solver.
+func (c SolverConfig) Validate() error {
+	switch {
+	case !isFinite(c.Dt) || c.Dt <= 0:
+		return fmt.Errorf("%w: dt must be positive, got %v", ErrInvalidConfig, c.Dt)
+	case !isFinite(c.Diffusion) || c.Diffusion < 0:
+		return fmt.Errorf("%w: diffusion must not be negative, got %v", ErrInvalidConfig, c.Diffusion)
+	case !isFinite(c.Viscosity) || c.Viscosity < 0:
+		return fmt.Errorf("%w: viscosity must not be negative, got %v", ErrInvalidConfig, c.Viscosity)
+	case c.Iterations < 1:
+		return fmt.Errorf("%w: iterations must be at least 1, got %d", ErrInvalidConfig, c.Iterations)
+	case c.Advection < AdvectionSemiLagrangian || c.Advection > AdvectionBFECC:
+		return fmt.Errorf("%w: unknown advection scheme %d", ErrInvalidConfig, c.Advection)
+	case !isFinite(c.CFL) || c.CFL <= 0:
+		return fmt.Errorf("%w: cfl must be positive, got %v", ErrInvalidConfig, c.CFL)
+	case c.MaxSubsteps < 1:
+		return fmt.Errorf("%w: max substeps must be at least 1, got %d", ErrInvalidConfig, c.MaxSubsteps)
+	case c.Workers < 0:
+		return fmt.Errorf("%w: workers must not be negative, got %d", ErrInvalidConfig, c.Workers)
+	case c.DyeChannels < 0:
+		return fmt.Errorf("%w: dye channels must not be negative, got %d", ErrInvalidConfig, c.DyeChannels)
+	case !isFinite(c.VorticityStrength) || c.VorticityStrength < 0:
+		return fmt.Errorf("%w: vorticity strength must not be negative, got %v", ErrInvalidConfig, c.VorticityStrength)
+	case !isFinite(c.BuoyancyWeight) || !isFinite(c.BuoyancyLift):
+		return fmt.Errorf("%w: buoyancy coefficients must be finite", ErrInvalidConfig)
+	case !isFinite(c.GravityX) || !isFinite(c.GravityY):
+		return fmt.Errorf("%w: gravity must be finite", ErrInvalidConfig)
+	case !isFinite(c.AmbientTemperature):
+		return fmt.Errorf("%w: ambient temperature must be finite", ErrInvalidConfig)
+	case !isFinite(c.TemperatureDiffusion) || c.TemperatureDiffusion < 0:
+		return fmt.Errorf("%w: temperature diffusion must not be negative, got %v", ErrInvalidConfig, c.TemperatureDiffusion)
+	case !isFinite(c.Cooling) || c.Cooling < 0:
+		return fmt.Errorf("%w: cooling must not be negative, got %v", ErrInvalidConfig, c.Cooling)
+	}
+	return c.Edges.validate()
+}
+
+// NewSolverConfig creates a fluid solver with a rectangular simulation grid of {nx} x {ny}
+// cells, using the parameters defined in {cfg}.
+func NewSolverConfig(nx, ny int, cfg SolverConfig) (*Solver, error) {
+	if nx < 1 || ny < 1 {
+		return nil, fmt.Errorf("%w: grid size must be positive, got %dx%d", ErrInvalidConfig, nx, ny)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	fs := NewSolverWH(nx, ny)
+	fs.config = cfg
+	fs.dt = cfg.Dt
+	fs.allocDyes()
+	fs.ResetTemperature()
+
+	return fs, nil
+}
+
+// Config returns the current solver configuration.
+func (fs *Solver) Config() SolverConfig {
+	return fs.config
+}
+
+// SetConfig updates the solver configuration. The change is applied from the next simulation step.
+// Changing the number of the dye channels clears the dye.
+func (fs *Solver) SetConfig(cfg SolverConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	fs.config = cfg
+	fs.allocDyes()
+
+	return nil
+}
+
+// isFinite reports whether {f} is neither NaN nor an infinity.
+func isFinite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
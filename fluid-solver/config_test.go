@@ -0,0 +1,91 @@
+package fluid
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestConfigInvalid(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		edit func(*SolverConfig)
+	}{
+		{"nan dt", func(c *SolverConfig) { c.Dt = math.NaN() }},
+		{"zero dt", func(c *SolverConfig) { c.Dt = 0 }},
+		{"negative dt", func(c *SolverConfig) { c.Dt = -0.1 }},
+		{"nan cfl", func(c *SolverConfig) { c.CFL = math.NaN() }},
+		{"zero cfl", func(c *SolverConfig) { c.CFL = 0 }},
+		{"negative cfl", func(c *SolverConfig) { c.CFL = -1 }},
+		{"zero substeps", func(c *SolverConfig) { c.MaxSubsteps = 0 }},
+		{"negative substeps", func(c *SolverConfig) { c.MaxSubsteps = -1 }},
+		{"negative workers", func(c *SolverConfig) { c.Workers = -1 }},
+		{"negative dye channels", func(c *SolverConfig) { c.DyeChannels = -1 }},
+		{"zero iterations", func(c *SolverConfig) { c.Iterations = 0 }},
+		{"nan diffusion", func(c *SolverConfig) { c.Diffusion = math.NaN() }},
+		{"infinite cooling", func(c *SolverConfig) { c.Cooling = math.Inf(1) }},
+		{"unknown advection", func(c *SolverConfig) { c.Advection = AdvectionBFECC + 1 }},
+	} {
+		cfg := DefaultConfig()
+		c.edit(&cfg)
+		if err := cfg.Validate(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: got %v, want ErrInvalidConfig", c.name, err)
+		}
+		if _, err := NewSolverConfig(8, 8, cfg); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: the solver was created, got %v", c.name, err)
+		}
+
+		// The rejected configuration leaves the running one untouched.
+		fs := NewSolver(8)
+		want := fs.Config()
+		if err := fs.SetConfig(cfg); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: the config was set, got %v", c.name, err)
+		}
+		if fs.Config() != want {
+			t.Errorf("%s: the rejected config changed the solver to %+v", c.name, fs.Config())
+		}
+	}
+
+	// No workers run the solver on the calling goroutine, and the dye channels are optional.
+	cfg := DefaultConfig()
+	cfg.Workers, cfg.DyeChannels = 0, 0
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("no workers and dye channels: %v", err)
+	}
+}
+
+func TestConfigDyeChannels(t *testing.T) {
+	fs := NewSolver(8)
+	cfg := fs.Config()
+	cfg.DyeChannels = 3
+	if err := fs.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if n := fs.DyeChannels(); n != 3 {
+		t.Fatalf("got %d dye channels, want 3", n)
+	}
+	for c := 0; c < 3; c++ {
+		d, err := fs.Dye(c)
+		if err != nil || len(d) != len(fs.d) {
+			t.Errorf("channel %d has %d cells, %v, want %d", c, len(d), err, len(fs.d))
+		}
+	}
+	if err := fs.AddDye(2, 4, 4, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Setting the same number of channels keeps the dye, changing it clears the dye.
+	cfg.Diffusion = 0
+	fs.SetConfig(cfg)
+	if fs.dyes[2].dOld[fs.idx(4, 4)] != 1 {
+		t.Error("the dye was cleared without changing the number of channels")
+	}
+	cfg.DyeChannels = 1
+	fs.SetConfig(cfg)
+	if n := fs.DyeChannels(); n != 1 {
+		t.Fatalf("got %d dye channels, want 1", n)
+	}
+	if _, err := fs.Dye(1); !errors.Is(err, ErrUnknownField) {
+		t.Errorf("the removed channel is still there, got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package fluid
+
+import "fmt"
+
+// dye is a colored scalar field carried by the fluid, independently of the density.
+type dye struct {
+	d    cell
+	dOld cell
+}
+
+// DyeChannels returns the number of the dye channels.
+func (fs *Solver) DyeChannels() int {
+	return len(fs.dyes)
+}
+
+// Dye returns a read-only view of the dye channel {c}, using the layout of the bulk field views.
+// The view is only valid until the next simulation step.
+func (fs *Solver) Dye(c int) ([]float64, error) {
+	if c < 0 || c >= len(fs.dyes) {
+		return nil, fmt.Errorf("%w: dye channel %d", ErrUnknownField, c)
+	}
+	return fs.dyes[c].d, nil
+}
+
+// AddDye adds {amount} of dye to the channel {c} at cell {x, y}.
+// The dye is injected into the fluid on the next density step.
+func (fs *Solver) AddDye(c, x, y int, amount float64) error {
+	if c < 0 || c >= len(fs.dyes) {
+		return fmt.Errorf("%w: dye channel %d", ErrUnknownField, c)
+	}
+	if !fs.inBounds(x, y) {
+		return fmt.Errorf("%w: (%d, %d)", ErrOutOfBounds, x, y)
+	}
+	fs.dyes[c].dOld[fs.idx(x, y)] += amount
+
+	return nil
+}
+
+// ResetDye clears all the dye channels.
+func (fs *Solver) ResetDye() {
+	for _, dy := range fs.dyes {
+		for i := range dy.d {
+			dy.d[i] = 0
+		}
+	}
+}
+
+// allocDyes allocates the dye channels defined by the configuration. The existing
+// channels are kept, as long as the number of channels doesn't change.
+func (fs *Solver) allocDyes() {
+	if len(fs.dyes) == fs.config.DyeChannels {
+		return
+	}
+	fs.dyes = make([]dye, fs.config.DyeChannels)
+	for c := range fs.dyes {
+		fs.dyes[c] = dye{
+			d:    make(cell, fs.numOfCells),
+			dOld: make(cell, fs.numOfCells),
+		}
+	}
+}
+
+// dyeStep moves the dye channels along the velocity field, the same way as the density.
+func (fs *Solver) dyeStep() {
+	for c := range fs.dyes {
+		dy := &fs.dyes[c]
+		fs.addSource(dy.d, dy.dOld)
+
+		dy.d, dy.dOld = dy.dOld, dy.d
+		fs.diffuse(BoundaryNone, dy.d, dy.dOld, fs.config.Diffusion)
+
+		dy.d, dy.dOld = dy.dOld, dy.d
+		fs.advect(BoundaryNone, dy.d, dy.dOld, fs.u, fs.v)
+
+		// reset for the next step
+		for i := range dy.dOld {
+			dy.dOld[i] = 0
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package fluid
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestDyeFollowsDensity(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DyeChannels = 2
+	cfg.Diffusion = 0.001
+	fs, err := NewSolverConfig(24, 16, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for j := 1; j <= 16; j++ {
+		for i := 1; i <= 24; i++ {
+			fs.Set(FieldU, i, j, 0.02*math.Sin(float64(j)/3))
+			fs.Set(FieldV, i, j, 0.01)
+		}
+	}
+
+	for step := 0; step < 10; step++ {
+		fs.Set(FieldDOld, 8, 6, 10)
+		fs.AddDye(0, 8, 6, 10)
+		fs.AddDye(1, 8, 6, 5)
+		fs.Step(0.2)
+	}
+
+	// The dye is advected and diffused like the density, and the channels are independent.
+	dye0, _ := fs.Dye(0)
+	dye1, _ := fs.Dye(1)
+	var spread int
+	for k, d := range fs.d {
+		if math.Abs(dye0[k]-d) > 1e-9 || math.Abs(dye1[k]-d/2) > 1e-9 {
+			t.Fatalf("cell %d: the dye is %v and %v, want %v and %v", k, dye0[k], dye1[k], d, d/2)
+		}
+		if d > 1e-3 {
+			spread++
+		}
+	}
+	if spread < 10 {
+		t.Errorf("the density covers %d cells, want it moved and diffused over more", spread)
+	}
+}
+
+func TestDyeInvalidChannel(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DyeChannels = 2
+	fs, err := NewSolverConfig(8, 8, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []int{-1, 2} {
+		if err := fs.AddDye(c, 4, 4, 1); !errors.Is(err, ErrUnknownField) {
+			t.Errorf("add to channel %d: got %v, want ErrUnknownField", c, err)
+		}
+		if _, err := fs.Dye(c); !errors.Is(err, ErrUnknownField) {
+			t.Errorf("view of channel %d: got %v, want ErrUnknownField", c, err)
+		}
+	}
+	if err := fs.AddDye(0, 10, 4, 1); !errors.Is(err, ErrOutOfBounds) {
+		t.Errorf("add out of the grid: got %v, want ErrOutOfBounds", err)
+	}
+	for _, dy := range fs.dyes {
+		for k := range dy.dOld {
+			if dy.dOld[k] != 0 {
+				t.Fatalf("the rejected dye was added to cell %d", k)
+			}
+		}
+	}
+}
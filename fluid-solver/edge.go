@@ -0,0 +1,132 @@
+package fluid
+
+import (
+	"fmt"
+	"math"
+)
+
+// EdgeType defines the boundary condition along an edge of the grid.
+type EdgeType int
+
+const (
+	// EdgeSolid is a closed wall, the fluid slides along it but doesn't flow through.
+	EdgeSolid EdgeType = iota
+	// EdgePeriodic wraps the fluid around to the opposite edge, which must be periodic too.
+	EdgePeriodic
+	// EdgeInflow pushes the fluid into the grid with a fixed velocity.
+	EdgeInflow
+	// EdgeOutflow lets the fluid leave the grid, keeping the fields unchanged across the edge.
+	EdgeOutflow
+	// EdgeNoSlip is a closed wall the fluid sticks to. The wall moves along the edge with the
+	// velocity component of U and V parallel to it, so it can be used as the lid driving a cavity.
+	EdgeNoSlip
+)
+
+// boundaryPressure marks the pressure field, which is fixed to zero along the outflow edges.
+const boundaryPressure BoundaryType = BoundaryTopBottom + 1
+
+// Edge defines the boundary condition of a grid edge.
+type Edge struct {
+	Type EdgeType
+	// U and V are the velocity components of the fluid entering through an inflow edge,
+	// or the velocity of a no-slip wall.
+	U, V float64
+}
+
+// Edges holds the boundary conditions of the four grid edges. The top edge is
+// the first grid row, which is displayed at the top of the screen.
+type Edges struct {
+	Left, Right, Top, Bottom Edge
+}
+
+// validate checks the edge types and the pairing of the periodic edges.
+func (e Edges) validate() error {
+	for _, edge := range []Edge{e.Left, e.Right, e.Top, e.Bottom} {
+		if edge.Type < EdgeSolid || edge.Type > EdgeNoSlip {
+			return fmt.Errorf("%w: unknown edge type %d", ErrInvalidConfig, edge.Type)
+		}
+		if !isFinite(edge.U) || !isFinite(edge.V) {
+			return fmt.Errorf("%w: edge velocity must be finite", ErrInvalidConfig)
+		}
+	}
+	if (e.Left.Type == EdgePeriodic) != (e.Right.Type == EdgePeriodic) {
+		return fmt.Errorf("%w: the left and right edges must be both periodic", ErrInvalidConfig)
+	}
+	if (e.Top.Type == EdgePeriodic) != (e.Bottom.Type == EdgePeriodic) {
+		return fmt.Errorf("%w: the top and bottom edges must be both periodic", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// hasOutflow checks if any of the edges lets the fluid leave the grid.
+func (e Edges) hasOutflow() bool {
+	return e.Left.Type == EdgeOutflow || e.Right.Type == EdgeOutflow ||
+		e.Top.Type == EdgeOutflow || e.Bottom.Type == EdgeOutflow
+}
+
+// ghost returns the value of the boundary cell next to the {inner} edge cell, where {opposite}
+// is the edge cell on the other side of the grid and {normal} is the velocity component
+// perpendicular to the edge.
+func (e Edge) ghost(bound, normal BoundaryType, inner, opposite float64) float64 {
+	switch e.Type {
+	case EdgePeriodic:
+		return opposite
+	case EdgeInflow:
+		switch bound {
+		case BoundaryLeftRight:
+			return e.U
+		case BoundaryTopBottom:
+			return e.V
+		}
+		return inner
+	case EdgeOutflow:
+		if bound == boundaryPressure {
+			return -inner
+		}
+		return inner
+	case EdgeNoSlip:
+		// The tangential velocity matches the wall velocity halfway between the edge and the boundary cell.
+		switch {
+		case bound == normal:
+			return -inner
+		case bound == BoundaryLeftRight:
+			return 2*e.U - inner
+		case bound == BoundaryTopBottom:
+			return 2*e.V - inner
+		}
+		return inner
+	}
+	if bound == normal {
+		return -inner
+	}
+	return inner
+}
+
+// coupling returns the contribution of a boundary cell to the diagonal of the Poisson matrix.
+func (e Edge) coupling() float64 {
+	switch e.Type {
+	case EdgePeriodic:
+		return 1
+	case EdgeOutflow:
+		// The pressure is zero halfway between the edge and the boundary cell.
+		return 2
+	}
+	return 0
+}
+
+// wrap moves the coordinate {x} into the range [0.5, n+0.5) of a periodic axis with {n} cells.
+// The infinite and NaN coordinates are returned unchanged.
+func wrap(x float64, n int) float64 {
+	if !isFinite(x) {
+		return x
+	}
+	x = math.Mod(x-0.5, float64(n))
+	if x < 0 {
+		x += float64(n)
+	}
+	if x >= float64(n) {
+		// A tiny negative remainder rounds up to n.
+		x = 0
+	}
+	return x + 0.5
+}
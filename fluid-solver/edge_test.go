@@ -0,0 +1,22 @@
+package fluid
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	for _, c := range []struct{ x, want float64 }{
+		{0.5, 0.5}, {8.5, 0.5}, {0.25, 8.25}, {-7.5, 0.5}, {1e9 + 0.5, 0.5}, {-1e-17, 8 - 1e-17},
+	} {
+		got := wrap(c.x, 8)
+		if got < 0.5 || got >= 8.5 || math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("wrap(%v) = %v, want %v", c.x, got, c.want)
+		}
+	}
+	for _, x := range []float64{math.Inf(1), math.Inf(-1), math.NaN()} {
+		if got := wrap(x, 8); isFinite(got) {
+			t.Errorf("wrap(%v) = %v, want it unchanged", x, got)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package fluid
+
+import (
+	"fmt"
+	"math"
+)
+
+// Falloff defines how the emission of an emitter fades out from its center towards its radius.
+type Falloff int
+
+const (
+	// FalloffConstant emits evenly into all the cells within the radius.
+	FalloffConstant Falloff = iota
+	// FalloffLinear fades out the emission linearly with the distance.
+	FalloffLinear
+	// FalloffGaussian fades out the emission with the gaussian kernel of the splats, which drops to 2%
+	// at the radius, and is cut off at one and a half times the radius.
+	FalloffGaussian
+)
+
+// Emitter is a source of density, dye, heat and velocity, added to the fluid on every simulation step.
+// The rates are amounts per unit of simulation time, emitted into every cell within the radius and
+// weighted by the falloff. The fields of the emitters added to a solver can be changed between the steps,
+// e.g. to move them around.
+type Emitter struct {
+	// X and Y are the position of the emitter center in cells, where the cell coordinates range from 1 to N.
+	X, Y float64
+	// Radius is the size of the emitter in cells. Below half a cell, only the cell of the center emits.
+	Radius float64
+	// Falloff defines how the emission fades out towards the radius.
+	Falloff Falloff
+
+	// Density is the density emission rate.
+	Density float64
+	// Dye is the emission rate of the dye channels relative to the density. The channels missing
+	// from the solver are ignored.
+	Dye []float64
+	// Heat is the heat emission rate.
+	Heat float64
+	// DirX and DirY are the direction of the emitted velocity. It doesn't have to be normalized.
+	DirX, DirY float64
+	// Speed is the magnitude of the emitted velocity.
+	Speed float64
+
+	// Delay is the simulation time the emitter waits for before it starts emitting.
+	Delay float64
+	// Lifetime is the simulation time the emitter emits for. The solver removes the emitter once its
+	// lifetime is over. Zero means the emitter emits until it's removed.
+	Lifetime float64
+
+	age float64
+}
+
+// Age returns the simulation time elapsed since the emitter has been added to the solver.
+func (e *Emitter) Age() float64 {
+	return e.age
+}
+
+// Active reports whether the emitter emits at its current age.
+func (e *Emitter) Active() bool {
+	return e.age >= e.Delay && !e.expired()
+}
+
+// expired reports whether the lifetime of the emitter is over.
+func (e *Emitter) expired() bool {
+	return e.Lifetime > 0 && e.age >= e.Delay+e.Lifetime
+}
+
+// validate checks if the emitter values are usable by the solver.
+func (e *Emitter) validate() error {
+	for _, v := range []float64{e.X, e.Y, e.Radius, e.Density, e.Heat, e.DirX, e.DirY, e.Speed, e.Delay, e.Lifetime} {
+		if !isFinite(v) {
+			return fmt.Errorf("fluid: emitter values must be finite")
+		}
+	}
+	for _, v := range e.Dye {
+		if !isFinite(v) {
+			return fmt.Errorf("fluid: emitter values must be finite")
+		}
+	}
+	switch {
+	case e.Radius < 0:
+		return fmt.Errorf("fluid: emitter radius must not be negative, got %v", e.Radius)
+	case e.Falloff < FalloffConstant || e.Falloff > FalloffGaussian:
+		return fmt.Errorf("fluid: unknown emitter falloff %d", e.Falloff)
+	case e.Delay < 0 || e.Lifetime < 0:
+		return fmt.Errorf("fluid: emitter delay and lifetime must not be negative")
+	}
+	return nil
+}
+
+// weight returns the falloff weight of a cell at {dist} from the emitter center.
+func (e *Emitter) weight(dist float64) float64 {
+	if e.Radius < 0.5 {
+		return 1
+	}
+	if e.Falloff == FalloffLinear {
+		return 1 - dist/e.Radius
+	}
+	return 1
+}
+
+// AddEmitter adds the emitter {e} to the solver. The emitter is kept by reference,
+// so its fields can be changed later, while it's part of the solver.
+func (fs *Solver) AddEmitter(e *Emitter) error {
+	if err := e.validate(); err != nil {
+		return err
+	}
+	e.age = 0
+	fs.emitters = append(fs.emitters, e)
+
+	return nil
+}
+
+// RemoveEmitter removes the emitter {e} from the solver.
+func (fs *Solver) RemoveEmitter(e *Emitter) {
+	for i, em := range fs.emitters {
+		if em == e {
+			fs.emitters = append(fs.emitters[:i], fs.emitters[i+1:]...)
+			return
+		}
+	}
+}
+
+// Emitters returns the emitters of the solver. The slice is only valid until the next simulation step.
+func (fs *Solver) Emitters() []*Emitter {
+	return fs.emitters
+}
+
+// ClearEmitters removes all the emitters.
+func (fs *Solver) ClearEmitters() {
+	fs.emitters = nil
+}
+
+// emit adds the sources of the active emitters, ages the emitters by {dt} and removes the expired ones.
+func (fs *Solver) emit(dt float64) {
+	alive := fs.emitters[:0]
+	for _, e := range fs.emitters {
+		if e.Active() {
+			fs.emitSources(e)
+		}
+		e.age += dt
+		if !e.expired() {
+			alive = append(alive, e)
+		}
+	}
+	for i := len(alive); i < len(fs.emitters); i++ {
+		fs.emitters[i] = nil
+	}
+	fs.emitters = alive
+}
+
+// emitSources adds the sources of the emitter {e} to the fluid cells covered by its falloff.
+func (fs *Solver) emitSources(e *Emitter) {
+	var du, dv float64
+	if l := math.Sqrt(e.DirX*e.DirX + e.DirY*e.DirY); l > 0 {
+		du, dv = e.Speed*e.DirX/l, e.Speed*e.DirY/l
+	}
+
+	// The fields of the emitter may have been changed since it was validated.
+	if !isFinite(e.X) || !isFinite(e.Y) || !isFinite(e.Radius) {
+		return
+	}
+	emit := func(k int, w float64) {
+		fs.uOld[k] += w * du
+		fs.vOld[k] += w * dv
+		fs.dOld[k] += w * e.Density
+		fs.tOld[k] += w * e.Heat
+		for c, amount := range e.Dye {
+			if c < len(fs.dyes) {
+				fs.dyes[c].dOld[k] += w * e.Density * amount
+			}
+		}
+	}
+
+	r := e.Radius
+	if r < 0.5 {
+		r = 0
+	}
+	if r > 0 && e.Falloff == FalloffGaussian {
+		// The splat kernel exp(-d²/s²) matches exp(-4d²/r²) with half of the radius.
+		fs.splat((e.X-0.5)/float64(fs.nx), (e.Y-0.5)/float64(fs.ny), 0.5*r/fs.scale(), emit)
+		return
+	}
+	x0, x1 := int(math.Max(1, math.Floor(e.X-r+0.5))), int(math.Min(float64(fs.nx), math.Floor(e.X+r+0.5)))
+	y0, y1 := int(math.Max(1, math.Floor(e.Y-r+0.5))), int(math.Min(float64(fs.ny), math.Floor(e.Y+r+0.5)))
+	for j := y0; j <= y1; j++ {
+		for i := x0; i <= x1; i++ {
+			k := fs.idx(i, j)
+			if fs.isSolid(k) {
+				continue
+			}
+			dx, dy := float64(i)-e.X, float64(j)-e.Y
+			dist := math.Sqrt(dx*dx + dy*dy)
+			if r > 0 && dist > r {
+				continue
+			}
+			emit(k, e.weight(dist))
+		}
+	}
+}
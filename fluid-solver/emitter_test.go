@@ -0,0 +1,139 @@
+package fluid
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEmitterStencil(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DyeChannels = 2
+	fs, err := NewSolverConfig(16, 16, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &Emitter{X: 8, Y: 8, Radius: 1, Density: 10, Dye: []float64{0.5, 1, 1}, DirX: 3, DirY: 4, Speed: 2}
+	if err := fs.AddEmitter(e); err != nil {
+		t.Fatal(err)
+	}
+	fs.emit(cfg.Dt)
+
+	var cells int
+	for k, d := range fs.dOld {
+		if d == 0 {
+			continue
+		}
+		cells++
+		if d != 10 || fs.dyes[0].dOld[k] != 5 || fs.dyes[1].dOld[k] != 10 {
+			t.Errorf("cell %d got density %v and dye (%v, %v), want 10 and (5, 10)", k, d, fs.dyes[0].dOld[k], fs.dyes[1].dOld[k])
+		}
+		if math.Abs(fs.uOld[k]-1.2) > 1e-12 || math.Abs(fs.vOld[k]-1.6) > 1e-12 {
+			t.Errorf("cell %d got velocity (%v, %v), want (1.2, 1.6)", k, fs.uOld[k], fs.vOld[k])
+		}
+	}
+	// The center and its four neighbours are within the radius of a single cell.
+	if cells != 5 {
+		t.Errorf("the emitter covered %d cells, want 5", cells)
+	}
+}
+
+func TestEmitterFalloff(t *testing.T) {
+	for _, falloff := range []Falloff{FalloffLinear, FalloffGaussian} {
+		fs := NewSolver(32)
+		fs.AddEmitter(&Emitter{X: 16, Y: 16, Radius: 6, Falloff: falloff, Density: 1})
+		fs.emit(0.1)
+
+		center, near, far := fs.dOld[fs.idx(16, 16)], fs.dOld[fs.idx(19, 16)], fs.dOld[fs.idx(22, 16)]
+		if center != 1 || near >= center || far >= near {
+			t.Errorf("falloff %d: the emission doesn't fade out, got %v, %v and %v", falloff, center, near, far)
+		}
+		if d := fs.dOld[fs.idx(16, 26)]; d != 0 {
+			t.Errorf("falloff %d: the cell out of the radius got %v", falloff, d)
+		}
+	}
+}
+
+func TestEmitterGaussianMatchesSplat(t *testing.T) {
+	emitted, splatted := NewSolver(32), NewSolver(32)
+	emitted.AddEmitter(&Emitter{X: 12, Y: 20, Radius: 6, Falloff: FalloffGaussian, Density: 1})
+	emitted.emit(0.1)
+	if err := splatted.SplatDensity((12-0.5)/32, (20-0.5)/32, 3.0/32, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	for k := range emitted.dOld {
+		if d := math.Abs(emitted.dOld[k] - splatted.dOld[k]); d > 1e-12 {
+			t.Fatalf("cell %d: the emitter added %v, the splat %v", k, emitted.dOld[k], splatted.dOld[k])
+		}
+	}
+}
+
+func TestEmitterSchedule(t *testing.T) {
+	const dt = 0.2
+
+	fs := NewSolver(16)
+	e := &Emitter{X: 8, Y: 8, Density: 1, Delay: 0.2, Lifetime: 0.4}
+	if err := fs.AddEmitter(e); err != nil {
+		t.Fatal(err)
+	}
+
+	var emitted []float64
+	for step := 0; step < 4; step++ {
+		k := fs.idx(8, 8)
+		before := fs.dOld[k]
+		fs.emit(dt)
+		emitted = append(emitted, fs.dOld[k]-before)
+	}
+	want := []float64{0, 1, 1, 0}
+	for i := range want {
+		if emitted[i] != want[i] {
+			t.Errorf("step %d emitted %v, want %v", i, emitted[i], want[i])
+		}
+	}
+	if len(fs.Emitters()) != 0 {
+		t.Errorf("the expired emitter wasn't removed")
+	}
+}
+
+func TestEmitterInvalid(t *testing.T) {
+	fs := NewSolver(16)
+	for _, e := range []*Emitter{
+		{X: math.NaN()},
+		{Radius: -1},
+		{Falloff: FalloffGaussian + 1},
+		{Lifetime: -1},
+		{Dye: []float64{math.Inf(1)}},
+	} {
+		if err := fs.AddEmitter(e); err == nil {
+			t.Errorf("expected an error for %+v", e)
+		}
+	}
+	if len(fs.Emitters()) != 0 {
+		t.Errorf("invalid emitters were added")
+	}
+
+	e := &Emitter{X: 4, Y: 4}
+	fs.AddEmitter(e)
+	fs.RemoveEmitter(e)
+	if len(fs.Emitters()) != 0 {
+		t.Errorf("the emitter wasn't removed")
+	}
+}
+
+func TestEmitterOffGrid(t *testing.T) {
+	fs := NewSolver(16)
+	huge := &Emitter{X: 8, Y: 8, Radius: 1e9, Density: 1}
+	far := &Emitter{X: -1e12, Y: 1e12, Radius: 2, Density: 1}
+	fs.AddEmitter(huge)
+	fs.AddEmitter(far)
+	far.X = math.NaN()
+	fs.emit(0.1)
+
+	for j := 1; j <= 16; j++ {
+		for i := 1; i <= 16; i++ {
+			if d := fs.dOld[fs.idx(i, j)]; d != 1 {
+				t.Fatalf("cell (%d, %d) got density %v, want 1 from the huge emitter only", i, j, d)
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package fluid
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Field identifies one of the solver's cell fields.
+type Field int
+
+const (
+	// FieldU is the horizontal velocity component.
+	FieldU Field = iota
+	// FieldV is the vertical velocity component.
+	FieldV
+	// FieldD is the density.
+	FieldD
+	// FieldUOld is the horizontal velocity source of the next step.
+	FieldUOld
+	// FieldVOld is the vertical velocity source of the next step.
+	FieldVOld
+	// FieldDOld is the density source of the next step.
+	FieldDOld
+	// FieldT is the temperature.
+	FieldT
+	// FieldTOld is the heat source of the next step.
+	FieldTOld
+)
+
+var (
+	// ErrUnknownField is returned when the field does not exist.
+	ErrUnknownField = errors.New("fluid: unknown field")
+	// ErrOutOfBounds is returned when the cell coordinates are outside of the grid.
+	ErrOutOfBounds = errors.New("fluid: cell out of bounds")
+	// ErrSizeMismatch is returned when a bulk write doesn't match the grid size.
+	ErrSizeMismatch = errors.New("fluid: size mismatch")
+)
+
+var fieldNames = map[Field]string{
+	FieldU:    "u",
+	FieldV:    "v",
+	FieldD:    "d",
+	FieldUOld: "uOld",
+	FieldVOld: "vOld",
+	FieldDOld: "dOld",
+	FieldT:    "t",
+	FieldTOld: "tOld",
+}
+
+// String returns the field name.
+func (f Field) String() string {
+	if name, ok := fieldNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("Field(%d)", int(f))
+}
+
+// Set sets the value of the field at cell {x, y}.
+// The cell coordinates include the boundary, so they range from 0 to N+1.
+func (fs *Solver) Set(f Field, x, y int, val float64) error {
+	c, err := fs.field(f)
+	if err != nil {
+		return err
+	}
+	if !fs.inBounds(x, y) {
+		return fmt.Errorf("%w: (%d, %d)", ErrOutOfBounds, x, y)
+	}
+	c[fs.idx(x, y)] = val
+	return nil
+}
+
+// Get returns the value of the field at cell {x, y}.
+// The cell coordinates include the boundary, so they range from 0 to N+1.
+func (fs *Solver) Get(f Field, x, y int) (float64, error) {
+	c, err := fs.field(f)
+	if err != nil {
+		return 0, err
+	}
+	if !fs.inBounds(x, y) {
+		return 0, fmt.Errorf("%w: (%d, %d)", ErrOutOfBounds, x, y)
+	}
+	return c[fs.idx(x, y)], nil
+}
+
+// Index returns the position of cell {x, y} inside the bulk field views.
+// The fields are stored row by row, including the boundary cells.
+func (fs *Solver) Index(x, y int) int {
+	return fs.idx(x, y)
+}
+
+// Density returns a read-only view of the density field.
+// The view is only valid until the next simulation step.
+func (fs *Solver) Density() []float64 {
+	return fs.d
+}
+
+// Velocity returns read-only views of the velocity field components.
+// The views are only valid until the next simulation step.
+func (fs *Solver) Velocity() (u, v []float64) {
+	return fs.u, fs.v
+}
+
+// SetDensity overwrites the density field with the values of {d}.
+func (fs *Solver) SetDensity(d []float64) error {
+	return fs.SetField(FieldD, d)
+}
+
+// SetVelocity overwrites the velocity field with the values of {u} and {v}.
+func (fs *Solver) SetVelocity(u, v []float64) error {
+	if err := fs.SetField(FieldU, u); err != nil {
+		return err
+	}
+	return fs.SetField(FieldV, v)
+}
+
+// SetField overwrites the whole field with {values}, which must have the
+// same layout as the bulk views (see Index).
+func (fs *Solver) SetField(f Field, values []float64) error {
+	c, err := fs.field(f)
+	if err != nil {
+		return err
+	}
+	if len(values) != len(c) {
+		return fmt.Errorf("%w: got %d cells, want %d", ErrSizeMismatch, len(values), len(c))
+	}
+	copy(c, values)
+	return nil
+}
+
+// field returns the cells of field {f}.
+func (fs *Solver) field(f Field) (cell, error) {
+	switch f {
+	case FieldU:
+		return fs.u, nil
+	case FieldV:
+		return fs.v, nil
+	case FieldD:
+		return fs.d, nil
+	case FieldUOld:
+		return fs.uOld, nil
+	case FieldVOld:
+		return fs.vOld, nil
+	case FieldDOld:
+		return fs.dOld, nil
+	case FieldT:
+		return fs.t, nil
+	case FieldTOld:
+		return fs.tOld, nil
+	}
+	return nil, fmt.Errorf("%w: %v", ErrUnknownField, f)
+}
+
+// inBounds checks if cell {x, y} is inside the grid, including the boundary.
+func (fs *Solver) inBounds(x, y int) bool {
+	return x >= 0 && x <= fs.nx+1 && y >= 0 && y <= fs.ny+1
+}
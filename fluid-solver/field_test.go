@@ -0,0 +1,80 @@
+package fluid
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFieldBounds(t *testing.T) {
+	fs := NewSolverWH(8, 4)
+
+	// The boundary cells are part of the grid, the cells beyond them aren't.
+	for _, c := range []struct{ x, y int }{{0, 0}, {9, 5}, {9, 0}, {0, 5}} {
+		if err := fs.Set(FieldD, c.x, c.y, 2); err != nil {
+			t.Errorf("set (%d, %d): %v", c.x, c.y, err)
+		}
+		if got, err := fs.Get(FieldD, c.x, c.y); err != nil || got != 2 {
+			t.Errorf("get (%d, %d) = %v, %v, want 2", c.x, c.y, got, err)
+		}
+	}
+	for _, c := range []struct{ x, y int }{{10, 0}, {0, 6}, {-1, 2}, {2, -1}} {
+		if err := fs.Set(FieldD, c.x, c.y, 2); !errors.Is(err, ErrOutOfBounds) {
+			t.Errorf("set (%d, %d): got %v, want ErrOutOfBounds", c.x, c.y, err)
+		}
+		if _, err := fs.Get(FieldD, c.x, c.y); !errors.Is(err, ErrOutOfBounds) {
+			t.Errorf("get (%d, %d): got %v, want ErrOutOfBounds", c.x, c.y, err)
+		}
+	}
+}
+
+func TestFieldUnknown(t *testing.T) {
+	fs := NewSolver(4)
+	for _, f := range []Field{-1, FieldTOld + 1} {
+		if err := fs.Set(f, 1, 1, 1); !errors.Is(err, ErrUnknownField) {
+			t.Errorf("set %v: got %v, want ErrUnknownField", f, err)
+		}
+		if _, err := fs.Get(f, 1, 1); !errors.Is(err, ErrUnknownField) {
+			t.Errorf("get %v: got %v, want ErrUnknownField", f, err)
+		}
+		if err := fs.SetField(f, make([]float64, len(fs.d))); !errors.Is(err, ErrUnknownField) {
+			t.Errorf("set field %v: got %v, want ErrUnknownField", f, err)
+		}
+	}
+	// The unknown field is reported before the bounds.
+	if _, err := fs.Get(FieldTOld+1, 100, 100); !errors.Is(err, ErrUnknownField) {
+		t.Errorf("got %v, want ErrUnknownField", err)
+	}
+	if err := fs.SetField(FieldD, make([]float64, 3)); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("got %v, want ErrSizeMismatch", err)
+	}
+}
+
+func TestFieldLegacyNames(t *testing.T) {
+	fs := NewSolver(4)
+	for name, f := range cellTypes {
+		fs.SetCell(name, 2, 3, 7)
+		if got, _ := fs.Get(f, 2, 3); got != 7 {
+			t.Errorf("SetCell(%q) set %v of %v, want 7", name, got, f)
+		}
+		if got := fs.GetCell(name, 2, 3); got != 7 {
+			t.Errorf("GetCell(%q) = %v, want 7", name, got)
+		}
+		if f.String() != name {
+			t.Errorf("field %d is named %q, want %q", int(f), f.String(), name)
+		}
+	}
+
+	// The unknown names and the cells out of the grid are ignored.
+	fs.SetCell("pressure", 2, 3, 1)
+	fs.SetCell(FieldD, 2, 2, 1)
+	fs.SetCell("d", 6, 6, 1)
+	if got := fs.GetCell("pressure", 2, 3); got != 0 {
+		t.Errorf("GetCell of an unknown name = %v, want 0", got)
+	}
+	if got := fs.GetCell("d", 6, 6); got != 0 {
+		t.Errorf("GetCell out of the grid = %v, want 0", got)
+	}
+	if got, _ := fs.Get(FieldD, 2, 2); got != 0 {
+		t.Errorf("SetCell with a Field set the cell to %v, want 0", got)
+	}
+}
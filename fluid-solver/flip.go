@@ -0,0 +1,331 @@
+package fluid
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// DefaultFlipBlend is the PIC/FLIP blend of the FLIP solver: mostly FLIP, with a bit of PIC damping the noise.
+const DefaultFlipBlend = 0.95
+
+// FLIP is a hybrid particle-grid fluid solver. The velocity is carried by the particles, which are
+// moved through the fluid without the numerical dissipation of the grid advection, while the forces
+// and the pressure projection are applied on the grid of the wrapped Solver.
+//
+// On each step the particle velocities are transferred to the grid, the forces are added and the grid
+// velocity is projected, then the particles get the change of the grid velocity (FLIP) blended with
+// the grid velocity itself (PIC). The particles are moved along the grid velocity and reseeded into
+// the cells they left empty. The density, the temperature and the dye are advected on the grid.
+// The viscosity of the configuration is not used.
+type FLIP struct {
+	fs        *Solver
+	blend     float64
+	perCell   int
+	particles []*Particle
+	rnd       *rand.Rand
+
+	uPrev  cell
+	vPrev  cell
+	weight cell
+	count  []int
+}
+
+// NewFLIP creates a FLIP solver on the grid of {fs}, seeding {perCell} particles into each fluid cell.
+// The particles get the velocity of the grid. The {blend} is the weight of the FLIP velocity update,
+// between 0 (pure PIC, smooth but dissipative) and 1 (pure FLIP, lively but noisy).
+func NewFLIP(fs *Solver, perCell int, blend float64) (*FLIP, error) {
+	if perCell < 1 {
+		return nil, fmt.Errorf("%w: particles per cell must be at least 1, got %d", ErrInvalidConfig, perCell)
+	}
+	f := &FLIP{fs: fs, perCell: perCell, rnd: rand.New(rand.NewSource(1))}
+	if err := f.SetBlend(blend); err != nil {
+		return nil, err
+	}
+	f.seed()
+
+	return f, nil
+}
+
+// Solver returns the grid solver, which holds the fields, the emitters and the obstacles.
+func (f *FLIP) Solver() *Solver {
+	return f.fs
+}
+
+// Blend returns the weight of the FLIP velocity update.
+func (f *FLIP) Blend() float64 {
+	return f.blend
+}
+
+// SetBlend sets the weight of the FLIP velocity update, between 0 (pure PIC) and 1 (pure FLIP).
+func (f *FLIP) SetBlend(blend float64) error {
+	if !isFinite(blend) || blend < 0 || blend > 1 {
+		return fmt.Errorf("%w: flip blend must be between 0 and 1, got %v", ErrInvalidConfig, blend)
+	}
+	f.blend = blend
+	return nil
+}
+
+// Particles returns the particles carrying the velocity. Their positions and velocities are given
+// in normalized coordinates, like the ones of SampleVelocity.
+func (f *FLIP) Particles() []*Particle {
+	return f.particles
+}
+
+// Step advances the simulation by {dt}. The time is split into substeps like in Solver.Step,
+// and the sources and the emitters are applied over the whole time step in the first substep,
+// in the same order as in Solver.Step.
+// It returns the number of the substeps run.
+func (f *FLIP) Step(dt float64) int {
+	fs := f.fs
+	if !isFinite(dt) || dt <= 0 {
+		return 0
+	}
+	fs.dt = dt
+	fs.emit(dt)
+
+	n := fs.substeps(dt)
+	for s := 0; s < n; s++ {
+		f.toGrid()
+		if s == 0 {
+			fs.dt = dt
+			fs.addVelocitySources()
+			fs.dt = dt / float64(n)
+		}
+		fs.addForces()
+		fs.project(fs.u, fs.v, fs.uOld, fs.vOld)
+		for i := 0; i < fs.numOfCells; i++ {
+			fs.uOld[i] = 0
+			fs.vOld[i] = 0
+		}
+
+		f.toParticles()
+		f.moveParticles(fs.dt)
+		f.reseed()
+		if s == 0 {
+			fs.dt = dt
+			fs.addScalarSources()
+			fs.dt = dt / float64(n)
+		}
+		fs.densityStep()
+	}
+	return n
+}
+
+// Reset clears the fields of the solver and stops the particles.
+func (f *FLIP) Reset() {
+	f.fs.Reset()
+	for _, p := range f.particles {
+		p.vx, p.vy = 0, 0
+	}
+}
+
+// Resize changes the grid size of the solver to {nx} x {ny} cells, like Solver.Resize.
+// The particles are seeded again, taking the resampled velocity of the grid.
+func (f *FLIP) Resize(nx, ny int) error {
+	if err := f.fs.Resize(nx, ny); err != nil {
+		return err
+	}
+	f.seed()
+	return nil
+}
+
+// seed places the particles at random positions in the fluid cells, with the velocity of the grid.
+func (f *FLIP) seed() {
+	fs := f.fs
+	f.uPrev = make(cell, fs.numOfCells)
+	f.vPrev = make(cell, fs.numOfCells)
+	f.weight = make(cell, fs.numOfCells)
+	f.count = make([]int, fs.numOfCells)
+
+	f.particles = f.particles[:0]
+	for j := 1; j <= fs.ny; j++ {
+		for i := 1; i <= fs.nx; i++ {
+			if fs.isSolid(fs.idx(i, j)) {
+				continue
+			}
+			for n := 0; n < f.perCell; n++ {
+				f.particles = append(f.particles, f.place(&Particle{}, i, j))
+			}
+		}
+	}
+}
+
+// reseed keeps the particles spread over the fluid. The particles tend to cluster, and the cells
+// left without particles would keep their velocity while the forces are still added to it.
+// So the particles beyond twice the seeding density of a cell are moved into the empty cells.
+func (f *FLIP) reseed() {
+	fs := f.fs
+	for k := range f.count {
+		f.count[k] = 0
+	}
+
+	var spare []*Particle
+	kept := f.particles[:0]
+	for _, p := range f.particles {
+		k := f.cellOf(p)
+		if f.count[k] >= 2*f.perCell {
+			spare = append(spare, p)
+			continue
+		}
+		f.count[k]++
+		kept = append(kept, p)
+	}
+	f.particles = kept
+
+	for j := 1; j <= fs.ny; j++ {
+		for i := 1; i <= fs.nx; i++ {
+			k := fs.idx(i, j)
+			if f.count[k] > 0 || fs.isSolid(k) {
+				continue
+			}
+			for n := 0; n < f.perCell; n++ {
+				p := &Particle{}
+				if l := len(spare); l > 0 {
+					p, spare = spare[l-1], spare[:l-1]
+				}
+				f.particles = append(f.particles, f.place(p, i, j))
+			}
+		}
+	}
+}
+
+// place moves the particle {p} to a random position in the cell {i, j}, with the velocity of the grid.
+func (f *FLIP) place(p *Particle, i, j int) *Particle {
+	fs := f.fs
+	p.x = (float64(i) - 1 + float64(f.rnd.Float64())) / float64(fs.nx)
+	p.y = (float64(j) - 1 + float64(f.rnd.Float64())) / float64(fs.ny)
+	p.vx, p.vy = fs.FlowVelocity(p.x, p.y)
+	return p
+}
+
+// reseat places the particle {p} again in its cell if its position or velocity isn't finite,
+// and reports whether it did.
+func (f *FLIP) reseat(p *Particle) bool {
+	if isFinite(p.x) && isFinite(p.y) && isFinite(p.vx) && isFinite(p.vy) {
+		return false
+	}
+	f.place(p, cellCoord(p.x, f.fs.nx), cellCoord(p.y, f.fs.ny))
+	return true
+}
+
+// cellOf returns the index of the grid cell containing the particle {p}.
+// The coordinates which aren't a number fall into the first cell.
+func (f *FLIP) cellOf(p *Particle) int {
+	fs := f.fs
+	return fs.idx(cellCoord(p.x, fs.nx), cellCoord(p.y, fs.ny))
+}
+
+// cellCoord returns the cell between 1 and {n} containing the normalized coordinate {x}.
+func cellCoord(x float64, n int) int {
+	c := x * float64(n)
+	if !(c >= 0) {
+		return 1
+	}
+	if c >= float64(n-1) {
+		return n
+	}
+	return int(c) + 1
+}
+
+// toGrid transfers the particle velocities to the grid, as the bilinearly weighted average of
+// the particles around each cell. The cells without particles keep their velocity.
+// The transferred velocity is kept in uPrev and vPrev for the FLIP update.
+func (f *FLIP) toGrid() {
+	fs := f.fs
+	for k := range f.weight {
+		f.uPrev[k], f.vPrev[k], f.weight[k] = 0, 0, 0
+	}
+	// The particle velocities are relative to the grid sides, the grid velocity to the longer one.
+	su, sv := float64(fs.nx)/fs.scale(), float64(fs.ny)/fs.scale()
+	for _, p := range f.particles {
+		f.reseat(p)
+		u, v := p.vx*su, p.vy*sv
+		fs.bilinear(p.x, p.y, func(k int, w float64) {
+			f.uPrev[k] += w * u
+			f.vPrev[k] += w * v
+			f.weight[k] += w
+		})
+	}
+	for j := 1; j <= fs.ny; j++ {
+		for i := 1; i <= fs.nx; i++ {
+			k := fs.idx(i, j)
+			if w := f.weight[k]; w > 0 {
+				fs.u[k], fs.v[k] = f.uPrev[k]/w, f.vPrev[k]/w
+			}
+		}
+	}
+	fs.setBoundary(BoundaryLeftRight, fs.u)
+	fs.setBoundary(BoundaryTopBottom, fs.v)
+	copy(f.uPrev, fs.u)
+	copy(f.vPrev, fs.v)
+}
+
+// toParticles updates the particle velocities from the projected grid velocity,
+// blending the change of the grid velocity (FLIP) with the grid velocity (PIC).
+func (f *FLIP) toParticles() {
+	fs := f.fs
+	su, sv := fs.scale()/float64(fs.nx), fs.scale()/float64(fs.ny)
+	for _, p := range f.particles {
+		px, py := fs.gridPos(p.x, p.y)
+		u, v := fs.interpolate(fs.u, px, py)*su, fs.interpolate(fs.v, px, py)*sv
+		du, dv := u-fs.interpolate(f.uPrev, px, py)*su, v-fs.interpolate(f.vPrev, px, py)*sv
+
+		p.vx = f.blend*(p.vx+du) + (1-f.blend)*u
+		p.vy = f.blend*(p.vy+dv) + (1-f.blend)*v
+	}
+}
+
+// moveParticles moves the particles along the grid velocity over {dt}. The particles don't enter
+// the solid cells, and the ones leaving the grid are handled by the edge conditions: they slide
+// along the walls, wrap around the periodic edges, and the ones leaving through an open edge
+// are recycled on the opposite side with the velocity of the grid. The particles traced to
+// a position which isn't finite are seeded again in their cell.
+func (f *FLIP) moveParticles(dt float64) {
+	fs := f.fs
+	edges := fs.config.Edges
+	for _, p := range f.particles {
+		if f.reseat(p) {
+			continue
+		}
+		x, y := fs.Trace(p.x, p.y, dt)
+		if !isFinite(x) || !isFinite(y) {
+			f.place(p, cellCoord(p.x, fs.nx), cellCoord(p.y, fs.ny))
+			continue
+		}
+
+		var rx, ry bool
+		x, p.vx, rx = edgeCondition(x, p.vx, edges.Left, edges.Right)
+		y, p.vy, ry = edgeCondition(y, p.vy, edges.Top, edges.Bottom)
+
+		px, py := p.x, p.y
+		p.x, p.y = x, y
+		if fs.isSolid(f.cellOf(p)) {
+			p.x, p.y = px, py
+			continue
+		}
+		if rx || ry {
+			p.vx, p.vy = fs.FlowVelocity(x, y)
+		}
+	}
+}
+
+// edgeCondition returns the normalized position {x} and the velocity {v} of a particle along an axis,
+// kept inside the grid by the edge conditions {lo} and {hi}. It reports whether the particle was
+// recycled through an open edge.
+func edgeCondition(x, v float64, lo, hi Edge) (float64, float64, bool) {
+	if x >= 0 && x <= 1 {
+		return x, v, false
+	}
+	e := lo
+	if x > 1 {
+		e = hi
+	}
+	switch e.Type {
+	case EdgePeriodic:
+		return x - math.Floor(x), v, false
+	case EdgeInflow, EdgeOutflow:
+		return x - math.Floor(x), v, true
+	}
+	return math.Min(math.Max(x, 0), 1), 0, false
+}
@@ -0,0 +1,152 @@
+package fluid
+
+import (
+	"math"
+	"testing"
+)
+
+// vortexSolver returns a solver with a single vortex in the middle of a closed box.
+func vortexSolver(t *testing.T) *Solver {
+	cfg := DefaultConfig()
+	cfg.Vorticity, cfg.Buoyancy = false, false
+	cfg.Iterations = 40
+	fs, err := NewSolverConfig(24, 24, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for j := 1; j <= 24; j++ {
+		for i := 1; i <= 24; i++ {
+			dx, dy := float64(i)-12.5, float64(j)-12.5
+			if r := math.Hypot(dx, dy); r < 8 {
+				fs.Set(FieldU, i, j, -0.01*dy)
+				fs.Set(FieldV, i, j, 0.01*dx)
+			}
+		}
+	}
+	fs.SetPressureSolver(&ConjugateGradient{Tolerance: 1e-8})
+	return fs
+}
+
+func TestFLIPKeepsEnergy(t *testing.T) {
+	grid := vortexSolver(t)
+	pic, err := NewFLIP(vortexSolver(t), 4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flip, err := NewFLIP(vortexSolver(t), 4, DefaultFlipBlend)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for step := 0; step < 40; step++ {
+		grid.Step(0.2)
+		pic.Step(0.2)
+		flip.Step(0.2)
+	}
+	eg, ep, ef := grid.Stats().KineticEnergy, pic.Solver().Stats().KineticEnergy, flip.Solver().Stats().KineticEnergy
+	if !(ef > ep && ef > eg) {
+		t.Errorf("the FLIP solver lost more energy than the others, got %v, want more than PIC %v and the grid %v", ef, ep, eg)
+	}
+	if sg, sf := grid.Stats().Enstrophy, flip.Solver().Stats().Enstrophy; sf <= sg {
+		t.Errorf("the FLIP solver lost more vorticity than the grid, got enstrophy %v, want more than %v", sf, sg)
+	}
+
+	// The grid velocity of a step is the projection of the particle velocities transferred to the grid.
+	fs := flip.Solver()
+	flip.toGrid()
+	want := vortexSolver(t)
+	copy(want.u, fs.u)
+	copy(want.v, fs.v)
+	want.project(want.u, want.v, want.uOld, want.vOld)
+	if n := flip.Step(0.05); n != 1 {
+		t.Fatalf("the step took %d substeps, want 1", n)
+	}
+	for k := range fs.u {
+		if math.Abs(fs.u[k]-want.u[k]) > 1e-12 || math.Abs(fs.v[k]-want.v[k]) > 1e-12 {
+			t.Fatalf("cell %d: the velocity is (%v, %v), want the projected (%v, %v)", k, fs.u[k], fs.v[k], want.u[k], want.v[k])
+		}
+	}
+
+	// The particles are kept spread over the whole grid.
+	count := make(map[int]int)
+	for _, p := range flip.Particles() {
+		if p.GetX() < 0 || p.GetX() > 1 || p.GetY() < 0 || p.GetY() > 1 {
+			t.Fatalf("the particle left the closed box: (%v, %v)", p.GetX(), p.GetY())
+		}
+		count[flip.cellOf(p)]++
+	}
+	for k, n := range count {
+		if n > 8 {
+			t.Errorf("cell %d has %d particles, want at most 8", k, n)
+		}
+	}
+	if len(count) != 24*24 {
+		t.Errorf("the particles cover %d cells, want all the %d", len(count), 24*24)
+	}
+}
+
+func TestFLIPEdges(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Edges = Edges{Left: Edge{Type: EdgeInflow, U: 0.01}, Right: Edge{Type: EdgeOutflow}}
+	fs, err := NewSolverConfig(16, 8, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewFLIP(fs, 2, DefaultFlipBlend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for step := 0; step < 50; step++ {
+		f.Step(0.5)
+	}
+
+	// The particles leaving on the right are recycled on the left, so they don't pile up.
+	var left int
+	for _, p := range f.Particles() {
+		if p.GetX() < 0 || p.GetX() > 1 || p.GetY() < 0 || p.GetY() > 1 {
+			t.Fatalf("the particle left the grid: (%v, %v)", p.GetX(), p.GetY())
+		}
+		if p.GetX() < 0.5 {
+			left++
+		}
+	}
+	if n := len(f.Particles()); left < n/4 {
+		t.Errorf("only %d of %d particles are on the left half of the tunnel", left, n)
+	}
+	if u, _ := fs.SampleVelocity(0.5, 0.5); u <= 0 {
+		t.Errorf("the fluid doesn't flow through the tunnel, got velocity %v", u)
+	}
+}
+
+func TestFLIPNonFinite(t *testing.T) {
+	f, err := NewFLIP(vortexSolver(t), 2, DefaultFlipBlend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := append([]*Particle(nil), f.Particles()[:4]...)
+	ps[0].x, ps[1].y = math.NaN(), math.Inf(1)
+	ps[2].vx, ps[3].vy = math.NaN(), math.Inf(-1)
+	f.Step(0.2)
+
+	// The particles are seeded again in the grid instead of spreading NaN over it.
+	for _, p := range ps {
+		if !(p.GetX() >= 0 && p.GetX() <= 1 && p.GetY() >= 0 && p.GetY() <= 1) || !isFinite(p.GetVx()) || !isFinite(p.GetVy()) {
+			t.Errorf("the particle wasn't reseated, got position (%v, %v) and velocity (%v, %v)", p.GetX(), p.GetY(), p.GetVx(), p.GetVy())
+		}
+	}
+	if err := f.Solver().CheckFinite(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFLIPInvalid(t *testing.T) {
+	fs := NewSolver(8)
+	if _, err := NewFLIP(fs, 0, 0.5); err == nil {
+		t.Error("expected an error for no particles per cell")
+	}
+	for _, blend := range []float64{-0.1, 1.1, math.NaN()} {
+		if _, err := NewFLIP(fs, 1, blend); err == nil {
+			t.Errorf("expected an error for blend %v", blend)
+		}
+	}
+}
@@ -10,25 +10,38 @@ import (
 type cell []float64
 
 type solver struct {
-	nx          int
-	ny          int
-	dt          float64
-	diffusion   float64
-	viscosity   float64
-	iterations  int
-	doVorticity bool
-	doBuoyancy  bool
-	numOfCells  int
+	nx         int
+	ny         int
+	numOfCells int
+	config     SolverConfig
+	dt         float64
 
 	u cell
 	v cell
 	d cell
+	t cell
 
 	uOld cell
 	vOld cell
 	dOld cell
+	tOld cell
 
 	curlData cell
+	advFwd   cell
+	advBwd   cell
+
+	dyes      []dye
+	obstacles []Obstacle
+
+	forceU         cell
+	forceV         cell
+	forceProviders []ForceProvider
+
+	emitters []*Emitter
+
+	pressure           PressureSolver
+	pressureIterations int
+	pressureResidual   float64
 }
 
 // BoundaryType is a type alias for int
@@ -40,79 +53,92 @@ const (
 	BoundaryTopBottom
 )
 
+// cellTypes maps the legacy cell type names to fields.
+var cellTypes = map[string]Field{
+	"u":    FieldU,
+	"v":    FieldV,
+	"d":    FieldD,
+	"uOld": FieldUOld,
+	"vOld": FieldVOld,
+	"dOld": FieldDOld,
+}
+
 // Solver is a global alias to solver for using outside of this package.
 type Solver solver
 
 // NewSolver defines the fluid solver general parameters, where {n} is the
 // number of fluid cells for the simulation grid in each dimension (NxN)
 func NewSolver(n int) *Solver {
+	return NewSolverWH(n, n)
+}
+
+// NewSolverWH creates a fluid solver with a rectangular simulation grid, where {nx} is the
+// number of fluid cells along the horizontal and {ny} along the vertical axis (NxM).
+// The grid cells are kept square, so the longer axis defines the unit length of the domain.
+func NewSolverWH(nx, ny int) *Solver {
 	fs := &Solver{
-		nx:          n,
-		ny:          n,
-		dt:          0.2,
-		diffusion:   0.0001,
-		viscosity:   0.0,
-		iterations:  10,
-		doVorticity: true,
-		doBuoyancy:  true,
+		nx:       nx,
+		ny:       ny,
+		config:   DefaultConfig(),
+		pressure: &GaussSeidel{},
 	}
-	fs.numOfCells = (n + 2) * (n + 2)
+	fs.dt = fs.config.Dt
+	fs.numOfCells = (nx + 2) * (ny + 2)
 	fs.u = make(cell, fs.numOfCells)
 	fs.v = make(cell, fs.numOfCells)
 	fs.d = make(cell, fs.numOfCells)
+	fs.t = make(cell, fs.numOfCells)
 
 	fs.uOld = make(cell, fs.numOfCells)
 	fs.vOld = make(cell, fs.numOfCells)
 	fs.dOld = make(cell, fs.numOfCells)
+	fs.tOld = make(cell, fs.numOfCells)
 
 	fs.curlData = make(cell, fs.numOfCells)
 
 	return fs
 }
 
+// Size returns the number of fluid cells (not including the boundary) in each dimension.
+func (fs *Solver) Size() (nx, ny int) {
+	return fs.nx, fs.ny
+}
+
 // SetCell sets the cell value of different types.
+//
+// Deprecated: SetCell silently ignores unknown cell types, use Set instead.
 func (fs *Solver) SetCell(cellType interface{}, x, y int, val float64) {
-	switch cellType {
-	case "u":
-		fs.u[fs.idx(x, y)] = val
-	case "v":
-		fs.v[fs.idx(x, y)] = val
-	case "d":
-		fs.d[fs.idx(x, y)] = val
-	case "uOld":
-		fs.uOld[fs.idx(x, y)] = val
-	case "vOld":
-		fs.vOld[fs.idx(x, y)] = val
-	case "dOld":
-		fs.dOld[fs.idx(x, y)] = val
+	if name, ok := cellType.(string); ok {
+		if f, ok := cellTypes[name]; ok {
+			fs.Set(f, x, y, val)
+		}
 	}
 }
 
 // GetCell gets the cell value of different types.
+//
+// Deprecated: GetCell silently ignores unknown cell types, use Get instead.
 func (fs *Solver) GetCell(cellType interface{}, x, y int) (result float64) {
-	switch cellType {
-	case "u":
-		result = fs.u[fs.idx(x, y)]
-	case "v":
-		result = fs.v[fs.idx(x, y)]
-	case "d":
-		result = fs.d[fs.idx(x, y)]
-	case "uOld":
-		result = fs.uOld[fs.idx(x, y)]
-	case "vOld":
-		result = fs.vOld[fs.idx(x, y)]
-	case "dOld":
-		result = fs.dOld[fs.idx(x, y)]
+	if name, ok := cellType.(string); ok {
+		if f, ok := cellTypes[name]; ok {
+			result, _ = fs.Get(f, x, y)
+		}
 	}
 	return
 }
 
-// DensityStep calculates the density step.
+// DensityStep calculates the density step, using the time step of the configuration.
 func (fs *Solver) DensityStep() {
+	fs.dt = fs.config.Dt
+	fs.densityStep()
+}
+
+// densityStep calculates the density step over the current time step.
+func (fs *Solver) densityStep() {
 	fs.addSource(fs.d, fs.dOld)
 
 	fs.swapD()
-	fs.diffuse(BoundaryNone, fs.d, fs.dOld, fs.diffusion)
+	fs.diffuse(BoundaryNone, fs.d, fs.dOld, fs.config.Diffusion)
 
 	fs.swapD()
 	fs.advect(BoundaryNone, fs.d, fs.dOld, fs.u, fs.v)
@@ -121,29 +147,27 @@ func (fs *Solver) DensityStep() {
 	for i := 0; i < fs.numOfCells; i++ {
 		fs.dOld[i] = 0
 	}
+
+	fs.temperatureStep()
+	fs.dyeStep()
 }
 
-// VelocityStep calculates the velocity step.
+// VelocityStep calculates the velocity step, using the time step of the configuration.
 func (fs *Solver) VelocityStep() {
-	fs.addSource(fs.u, fs.uOld)
-	fs.addSource(fs.v, fs.vOld)
-
-	if fs.doVorticity {
-		fs.calcVorticityConfinement(fs.uOld, fs.vOld)
-		fs.addSource(fs.u, fs.uOld)
-		fs.addSource(fs.v, fs.vOld)
-	}
+	fs.dt = fs.config.Dt
+	fs.emit(fs.dt)
+	fs.velocityStep()
+}
 
-	if fs.doBuoyancy {
-		fs.buoyancy(fs.vOld)
-		fs.addSource(fs.v, fs.vOld)
-	}
+// velocityStep calculates the velocity step over the current time step.
+func (fs *Solver) velocityStep() {
+	fs.addForces()
 
 	fs.swapU()
-	fs.diffuse(BoundaryLeftRight, fs.u, fs.uOld, fs.viscosity)
+	fs.diffuse(BoundaryLeftRight, fs.u, fs.uOld, fs.config.Viscosity)
 
 	fs.swapV()
-	fs.diffuse(BoundaryTopBottom, fs.v, fs.vOld, fs.viscosity)
+	fs.diffuse(BoundaryTopBottom, fs.v, fs.vOld, fs.config.Viscosity)
 
 	fs.project(fs.u, fs.v, fs.uOld, fs.vOld)
 	fs.swapU()
@@ -161,6 +185,30 @@ func (fs *Solver) VelocityStep() {
 	}
 }
 
+// addForces adds the velocity sources, the vorticity confinement, the buoyancy
+// and the external forces to the velocity field over the current time step.
+func (fs *Solver) addForces() {
+	fs.addSource(fs.u, fs.uOld)
+	fs.addSource(fs.v, fs.vOld)
+
+	if fs.config.Vorticity {
+		fs.calcVorticityConfinement(fs.uOld, fs.vOld)
+		fs.addSource(fs.u, fs.uOld)
+		fs.addSource(fs.v, fs.vOld)
+	}
+
+	if fs.config.Buoyancy {
+		fs.buoyancy(fs.vOld)
+		fs.addSource(fs.v, fs.vOld)
+	}
+
+	if fs.hasForces() {
+		fs.externalForces(fs.uOld, fs.vOld)
+		fs.addSource(fs.u, fs.uOld)
+		fs.addSource(fs.v, fs.vOld)
+	}
+}
+
 // ResetDensity resets the density cells.
 func (fs *Solver) ResetDensity() {
 	for i := 0; i < fs.numOfCells; i++ {
@@ -177,6 +225,16 @@ func (fs *Solver) ResetVelocity() {
 	}
 }
 
+// Reset clears all the fields and the pending sources, keeping the configuration and the obstacles.
+func (fs *Solver) Reset() {
+	for _, c := range fs.snapshotFields() {
+		for i := range c {
+			c[i] = 0
+		}
+	}
+	fs.ResetTemperature()
+}
+
 // swapU swaps velocity x reference.
 func (fs *Solver) swapU() {
 	tmp := fs.u
@@ -207,8 +265,8 @@ func (fs *Solver) addSource(x, s cell) {
 
 // curls calculates the curl at cell (i, j).
 func (fs *Solver) curl(i, j int) float64 {
-	duDy := fs.u[fs.idx(i, j+1)] - fs.u[fs.idx(i, j-1)]*0.5
-	dvDx := fs.v[fs.idx(i+1, j)] - fs.v[fs.idx(i-1, j)]*0.5
+	duDy := (fs.u[fs.idx(i, j+1)] - fs.u[fs.idx(i, j-1)]) * 0.5
+	dvDx := (fs.v[fs.idx(i+1, j)] - fs.v[fs.idx(i-1, j)]) * 0.5
 
 	return duDy - dvDx
 }
@@ -220,13 +278,17 @@ func (fs *Solver) calcVorticityConfinement(x, y cell) {
 		dx, dy, norm, v float64
 	)
 
+	// Calculate the magnitude of curl(i, j) for each cell, before taking its gradient.
 	for i = 1; i <= fs.nx; i++ {
 		for j = 1; j <= fs.ny; j++ {
-			// Calculate the magnitude of curl(i, j) for each cell
 			fs.curlData[fs.idx(i, j)] = math.Abs(fs.curl(i, j))
+		}
+	}
 
-			dx = fs.curlData[fs.idx(i+1, j)] - fs.curlData[fs.idx(i-1, j)]*0.5
-			dy = fs.curlData[fs.idx(i, j+1)] - fs.curlData[fs.idx(i, j-1)]*0.5
+	for i = 1; i <= fs.nx; i++ {
+		for j = 1; j <= fs.ny; j++ {
+			dx = (fs.curlData[fs.idx(i+1, j)] - fs.curlData[fs.idx(i-1, j)]) * 0.5
+			dy = (fs.curlData[fs.idx(i, j+1)] - fs.curlData[fs.idx(i, j-1)]) * 0.5
 
 			norm = math.Sqrt((dx * dx) + (dy * dy))
 			if norm == 0 {
@@ -238,33 +300,25 @@ func (fs *Solver) calcVorticityConfinement(x, y cell) {
 
 			v = fs.curl(i, j)
 
-			x[fs.idx(i, j)] = dy * v * -1
-			y[fs.idx(i, j)] = dx * v
+			x[fs.idx(i, j)] = dy * v * -fs.config.VorticityStrength
+			y[fs.idx(i, j)] = dx * v * fs.config.VorticityStrength
 		}
 	}
 }
 
-// buoyancy calculates the buoyancy force for the grid.
+// buoyancy calculates the buoyancy force for the grid. The smoke density pulls the
+// fluid down, while the temperature above the ambient temperature lifts it up.
 func (fs *Solver) buoyancy(buoy cell) cell {
 	var (
-		i, j int
-		tAmb float64
-		a    = 0.000625
-		b    = 0.015
+		a    = fs.config.BuoyancyWeight
+		b    = fs.config.BuoyancyLift
+		tAmb = fs.config.AmbientTemperature
 	)
 
-	// Sum all temperatures (faster)
-	for i := 0; i < len(fs.d); i++ {
-		tAmb += fs.d[i]
-	}
-
-	// Calculate the average temperature of the grid
-	tAmb /= float64(fs.nx * fs.ny)
-
 	// For each cell compute the bouyancy force
-	for i = 1; i <= fs.nx; i++ {
-		for j = 1; j <= fs.ny; j++ {
-			buoy[fs.idx(i, j)] = a*fs.d[fs.idx(i, j)] + -b*(fs.d[fs.idx(i, j)]-tAmb)
+	for i := 1; i <= fs.nx; i++ {
+		for j := 1; j <= fs.ny; j++ {
+			buoy[fs.idx(i, j)] = a*fs.d[fs.idx(i, j)] + -b*(fs.t[fs.idx(i, j)]-tAmb)
 		}
 	}
 	return buoy
@@ -272,18 +326,28 @@ func (fs *Solver) buoyancy(buoy cell) cell {
 
 // diffuse diffuses the density between neighbouring cells.
 func (fs *Solver) diffuse(bound BoundaryType, x, x0 cell, diffusion float64) {
-	a := fs.dt * diffusion * float64(fs.nx*fs.ny)
+	a := fs.dt * diffusion * fs.scale() * fs.scale()
 	fs.linearSolve(bound, x, x0, a, 1.0+4.0*a)
 }
 
+// linearSolve solves the linear system with Gauss-Seidel relaxation. The cells are updated
+// in red-black order, so the rows can be split between workers and the result doesn't
+// depend on the number of workers.
 func (fs *Solver) linearSolve(bound BoundaryType, x, x0 cell, a, c float64) {
 	invC := 1.0 / c
 
-	for k := 0; k < fs.iterations; k++ {
-		for i := 1; i <= fs.nx; i++ {
-			for j := 1; j <= fs.ny; j++ {
-				x[fs.idx(i, j)] = (x0[fs.idx(i, j)] + a*(x[fs.idx(i-1, j)]+x[fs.idx(i+1, j)]+x[fs.idx(i, j-1)]+x[fs.idx(i, j+1)])) * invC
-			}
+	for k := 0; k < fs.config.Iterations; k++ {
+		for color := 0; color < 2; color++ {
+			fs.parallel(func(j0, j1 int) {
+				for j := j0; j <= j1; j++ {
+					for i := 2 - (j+color)%2; i <= fs.nx; i += 2 {
+						if fs.isSolid(fs.idx(i, j)) {
+							continue
+						}
+						x[fs.idx(i, j)] = (x0[fs.idx(i, j)] + a*(x[fs.idx(i-1, j)]+x[fs.idx(i+1, j)]+x[fs.idx(i, j-1)]+x[fs.idx(i, j+1)])) * invC
+					}
+				}
+			})
 		}
 		fs.setBoundary(bound, x)
 	}
@@ -292,102 +356,175 @@ func (fs *Solver) linearSolve(bound BoundaryType, x, x0 cell, a, c float64) {
 // project solves the Poisson Equation.
 func (fs *Solver) project(u, v, p, div cell) {
 	// Calculate the gradient field
-	h := 1.0 / float64(fs.ny)
-	for i := 1; i <= fs.nx; i++ {
-		for j := 1; j <= fs.ny; j++ {
-			div[fs.idx(i, j)] = -0.5 * h * (u[fs.idx(i+1, j)] - u[fs.idx(i-1, j)] + v[fs.idx(i, j+1)] - v[fs.idx(i, j-1)])
-			p[fs.idx(i, j)] = 0
+	h := 1.0 / fs.scale()
+	fs.parallel(func(j0, j1 int) {
+		for j := j0; j <= j1; j++ {
+			for i := 1; i <= fs.nx; i++ {
+				p[fs.idx(i, j)] = 0
+				if fs.isSolid(fs.idx(i, j)) {
+					div[fs.idx(i, j)] = 0
+					continue
+				}
+				div[fs.idx(i, j)] = -h * fs.divergence(u, v, i, j)
+			}
 		}
+	})
+	if !fs.config.Edges.hasOutflow() {
+		// Without outflow edges the pressure is only defined up to a constant.
+		fs.grid().removeMean(div)
 	}
 	fs.setBoundary(BoundaryNone, div)
-	fs.setBoundary(BoundaryNone, p)
+	fs.setBoundary(boundaryPressure, p)
 
 	// Solve the Poisson equation
-	fs.linearSolve(BoundaryNone, p, div, 1, 4)
+	fs.pressureIterations, fs.pressureResidual = fs.pressure.Solve(fs, p, div)
+	fs.setBoundary(boundaryPressure, p)
 
 	// Substract the gradient field from the velocity field to get the mass conserving velocity field.
-	for i := 1; i <= fs.nx; i++ {
-		for j := 1; j <= fs.ny; j++ {
-			u[fs.idx(i, j)] = 0.5 * (p[fs.idx(i+1, j)] - p[fs.idx(i-1, j)]) / h
-			v[fs.idx(i, j)] = 0.5 * (p[fs.idx(i, j+1)] - p[fs.idx(i, j-1)]) / h
+	fs.parallel(func(j0, j1 int) {
+		for j := j0; j <= j1; j++ {
+			for i := 1; i <= fs.nx; i++ {
+				if fs.isSolid(fs.idx(i, j)) {
+					continue
+				}
+				u[fs.idx(i, j)] -= 0.5 * (p[fs.idx(i+1, j)] - p[fs.idx(i-1, j)]) / h
+				v[fs.idx(i, j)] -= 0.5 * (p[fs.idx(i, j+1)] - p[fs.idx(i, j-1)]) / h
+			}
 		}
-	}
+	})
 	fs.setBoundary(BoundaryLeftRight, u)
 	fs.setBoundary(BoundaryTopBottom, v)
 }
 
-// advect moves the density through the static velocity field.
-func (fs *Solver) advect(bound BoundaryType, d, d0, u, v cell) {
-	var (
-		i, j, i0, j0, i1, j1 int
-		x, y, s0, t0, s1, t1 float64
-		dt0, dt1             float64
-	)
-	dt0 = fs.dt * float64(fs.nx)
-	dt1 = fs.dt * float64(fs.ny)
+// divergence returns the divergence of the velocity field at cell (i, j), in grid units.
+func (fs *Solver) divergence(u, v cell, i, j int) float64 {
+	return 0.5 * (u[fs.idx(i+1, j)] - u[fs.idx(i-1, j)] + v[fs.idx(i, j+1)] - v[fs.idx(i, j-1)])
+}
 
-	for i = 1; i <= fs.nx; i++ {
-		for j = 1; j <= fs.ny; j++ {
-			x = float64(i) - dt0*u[fs.idx(i, j)]
-			y = float64(j) - dt1*v[fs.idx(i, j)]
-			if x < 0.5 {
-				x = 0.5
-			}
-			if x > float64(fs.nx)+0.5 {
-				x = float64(fs.nx) + 0.5
-			}
-			i0 = (int(x) | int(x))
-			i1 = i0 + 1
+// advect moves the density through the static velocity field, using the configured advection scheme.
+func (fs *Solver) advect(bound BoundaryType, d, d0, u, v cell) {
+	switch fs.config.Advection {
+	case AdvectionMacCormack:
+		fs.advectMacCormack(bound, d, d0, u, v)
+	case AdvectionBFECC:
+		fs.advectBFECC(bound, d, d0, u, v)
+	default:
+		fs.semiLagrangian(d, d0, u, v, fs.dt)
+	}
+	fs.setBoundary(bound, d)
+}
 
-			if y < 0.5 {
-				y = 0.5
+// semiLagrangian traces the cells back along the velocity field over the time step {dt}
+// and interpolates the values of {d0} at the traced positions.
+func (fs *Solver) semiLagrangian(d, d0, u, v cell, dt float64) {
+	fs.parallel(func(j0, j1 int) {
+		for j := j0; j <= j1; j++ {
+			for i := 1; i <= fs.nx; i++ {
+				if fs.isSolid(fs.idx(i, j)) {
+					continue
+				}
+				x, y := fs.backtrace(i, j, u, v, dt)
+				d[fs.idx(i, j)] = fs.interpolate(d0, x, y)
 			}
-			if y > float64(fs.ny)+0.5 {
-				y = float64(fs.ny) + 0.5
-			}
-			j0 = (int(y) | int(y))
-			j1 = j0 + 1
-			s1 = x - float64(i0)
-			s0 = 1 - s1
-			t1 = y - float64(j0)
-			t0 = 1 - t1
-
-			d[fs.idx(i, j)] = s0*(t0*d0[fs.idx(i0, j0)]+t1*d0[fs.idx(i0, j1)]) +
-				s1*(t0*d0[fs.idx(i1, j0)]+t1*d0[fs.idx(i1, j1)])
 		}
+	})
+}
+
+// backtrace returns the position the fluid at cell (i, j) comes from over the time step {dt}.
+// The position is wrapped around the periodic edges and clamped to the grid otherwise.
+func (fs *Solver) backtrace(i, j int, u, v cell, dt float64) (x, y float64) {
+	dt0 := dt * fs.scale()
+	x = float64(i) - dt0*u[fs.idx(i, j)]
+	y = float64(j) - dt0*v[fs.idx(i, j)]
+
+	return fs.clampPos(x, y)
+}
+
+// clampPos keeps the grid position {x, y} within the range of the bilinear interpolation.
+// The positions beyond the periodic edges are wrapped around the grid, and the NaN coordinates
+// are clamped to the top left edges.
+func (fs *Solver) clampPos(x, y float64) (float64, float64) {
+	if fs.config.Edges.Left.Type == EdgePeriodic {
+		x = wrap(x, fs.nx)
 	}
-	fs.setBoundary(bound, d)
+	if fs.config.Edges.Top.Type == EdgePeriodic {
+		y = wrap(y, fs.ny)
+	}
+	if !(x >= 0.5) {
+		x = 0.5
+	}
+	if x > float64(fs.nx)+0.5 {
+		x = float64(fs.nx) + 0.5
+	}
+	if !(y >= 0.5) {
+		y = 0.5
+	}
+	if y > float64(fs.ny)+0.5 {
+		y = float64(fs.ny) + 0.5
+	}
+	return x, y
+}
+
+// interpolate returns the bilinear interpolation of {d0} at the grid position {x, y}.
+func (fs *Solver) interpolate(d0 cell, x, y float64) float64 {
+	i0, j0 := int(x), int(y)
+	i1, j1 := i0+1, j0+1
+
+	s1 := x - float64(i0)
+	s0 := 1 - s1
+	t1 := y - float64(j0)
+	t0 := 1 - t1
+
+	return s0*(t0*d0[fs.idx(i0, j0)]+t1*d0[fs.idx(i0, j1)]) +
+		s1*(t0*d0[fs.idx(i1, j0)]+t1*d0[fs.idx(i1, j1)])
 }
 
 // setBoundary sets the boundary conditions.
 func (fs *Solver) setBoundary(bound BoundaryType, x cell) {
-	for i := 1; i <= fs.nx; i++ {
-		if bound == BoundaryLeftRight {
-			x[fs.idx(0, i)] = -x[fs.idx(1, i)]
-			x[fs.idx(fs.nx+1, i)] = -x[fs.idx(fs.nx, i)]
-		} else {
-			x[fs.idx(0, i)] = x[fs.idx(1, i)]
-			x[fs.idx(fs.nx+1, i)] = x[fs.idx(fs.nx, i)]
-		}
+	edges := fs.config.Edges
+
+	for j := 1; j <= fs.ny; j++ {
+		x[fs.idx(0, j)] = edges.Left.ghost(bound, BoundaryLeftRight, x[fs.idx(1, j)], x[fs.idx(fs.nx, j)])
+		x[fs.idx(fs.nx+1, j)] = edges.Right.ghost(bound, BoundaryLeftRight, x[fs.idx(fs.nx, j)], x[fs.idx(1, j)])
 	}
 
-	for i := 1; i <= fs.ny; i++ {
-		if bound == BoundaryTopBottom {
-			x[fs.idx(i, 0)] = -x[fs.idx(i, 1)]
-			x[fs.idx(i, fs.ny+1)] = -x[fs.idx(i, fs.ny)]
-		} else {
-			x[fs.idx(i, 0)] = x[fs.idx(i, 1)]
-			x[fs.idx(i, fs.ny+1)] = x[fs.idx(i, fs.ny)]
-		}
+	for i := 1; i <= fs.nx; i++ {
+		x[fs.idx(i, 0)] = edges.Top.ghost(bound, BoundaryTopBottom, x[fs.idx(i, 1)], x[fs.idx(i, fs.ny)])
+		x[fs.idx(i, fs.ny+1)] = edges.Bottom.ghost(bound, BoundaryTopBottom, x[fs.idx(i, fs.ny)], x[fs.idx(i, 1)])
 	}
 
 	x[fs.idx(0, 0)] = 0.5 * (x[fs.idx(1, 0)] + x[fs.idx(0, 1)])
 	x[fs.idx(0, fs.ny+1)] = 0.5 * (x[fs.idx(1, fs.ny+1)] + x[fs.idx(0, fs.ny)])
 	x[fs.idx(fs.nx+1, 0)] = 0.5 * (x[fs.idx(fs.nx, 0)] + x[fs.idx(fs.nx+1, 1)])
 	x[fs.idx(fs.nx+1, fs.ny+1)] = 0.5 * (x[fs.idx(fs.nx, fs.ny+1)] + x[fs.idx(fs.nx+1, fs.ny)])
+
+	// The corners of the periodic edges wrap around, like the rest of the boundary cells.
+	if edges.Left.Type == EdgePeriodic {
+		for _, j := range [2]int{0, fs.ny + 1} {
+			x[fs.idx(0, j)] = x[fs.idx(fs.nx, j)]
+			x[fs.idx(fs.nx+1, j)] = x[fs.idx(1, j)]
+		}
+	} else if edges.Top.Type == EdgePeriodic {
+		for _, i := range [2]int{0, fs.nx + 1} {
+			x[fs.idx(i, 0)] = x[fs.idx(i, fs.ny)]
+			x[fs.idx(i, fs.ny+1)] = x[fs.idx(i, 1)]
+		}
+	}
+
+	if fs.obstacles != nil {
+		fs.setObstacleBoundary(bound, x)
+	}
 }
 
 // idx returns the cell's index (position).
 func (fs *Solver) idx(i, j int) int {
-	return i + (fs.ny+2)*j
+	return i + (fs.nx+2)*j
+}
+
+// scale returns the number of cells per unit length, which is defined by the longer grid axis.
+func (fs *Solver) scale() float64 {
+	if fs.nx > fs.ny {
+		return float64(fs.nx)
+	}
+	return float64(fs.ny)
 }
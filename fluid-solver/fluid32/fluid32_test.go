@@ -0,0 +1,88 @@
+package fluid32
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	fluid "github.com/esimov/ascii-fluid/fluid-solver"
+)
+
+// newBenchSolver creates a solver with a dense velocity and density field,
+// the same way as the benchmarks of the float64 solver.
+func newBenchSolver(tb testing.TB, n, workers int) *Solver {
+	cfg := DefaultConfig()
+	cfg.Workers = workers
+	fs, err := NewSolverConfig(n, n, cfg)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for j := 1; j <= n; j++ {
+		for i := 1; i <= n; i++ {
+			fs.uOld[fs.idx(i, j)] = float32((i*7+j*3)%11) - 5
+			fs.vOld[fs.idx(i, j)] = float32((i*5+j*13)%7) - 3
+			fs.dOld[fs.idx(i, j)] = float32((i + j) % 17)
+		}
+	}
+	return fs
+}
+
+func TestMatchesFloat64(t *testing.T) {
+	const n = 48
+
+	// The vorticity confinement normalizes tiny gradients, which amplifies the rounding differences.
+	cfg32, cfg64 := DefaultConfig(), fluid.DefaultConfig()
+	cfg32.Vorticity, cfg64.Vorticity = false, false
+
+	fs32, err := NewSolverConfig(n, n, cfg32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs64, err := fluid.NewSolverConfig(n, n, cfg64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for step := 0; step < 20; step++ {
+		fs32.Set(FieldUOld, 20, 24, 2)
+		fs32.Set(FieldDOld, 20, 24, 50)
+		fs64.Set(fluid.FieldUOld, 20, 24, 2)
+		fs64.Set(fluid.FieldDOld, 20, 24, 50)
+		fs32.Step(0.2)
+		fs64.Step(0.2)
+	}
+
+	d32, d64 := fs32.Density(), fs64.Density()
+	var maxDiff, peak float64
+	for k := range d64 {
+		maxDiff = math.Max(maxDiff, math.Abs(float64(d32[k])-d64[k]))
+		peak = math.Max(peak, d64[k])
+	}
+	t.Logf("max density difference %.2e, peak density %.2f", maxDiff, peak)
+	if maxDiff > 2e-3*peak {
+		t.Errorf("the density differs from the float64 solver by %.2e", maxDiff)
+	}
+}
+
+func BenchmarkVelocityStep256(b *testing.B) {
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			fs := newBenchSolver(b, 256, workers)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				fs.VelocityStep()
+			}
+		})
+	}
+}
+
+func BenchmarkDensityStep256(b *testing.B) {
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			fs := newBenchSolver(b, 256, workers)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				fs.DensityStep()
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package fluid
+
+import (
+	"fmt"
+	"math"
+)
+
+// ForceProvider computes an external force acting on the fluid, like an attractor or a vortex.
+// The providers added to the solver are evaluated on every velocity step.
+type ForceProvider interface {
+	// Force returns the force acting on the fluid cell {x, y} which moves with the velocity {u, v}.
+	// The cell coordinates range from 1 to N. It's called on the solver's goroutine.
+	Force(x, y int, u, v float64) (fx, fy float64)
+}
+
+// ForceFunc is an adapter allowing the use of ordinary functions as force providers.
+type ForceFunc func(x, y int, u, v float64) (fx, fy float64)
+
+// Force calls f(x, y, u, v).
+func (f ForceFunc) Force(x, y int, u, v float64) (fx, fy float64) {
+	return f(x, y, u, v)
+}
+
+// Attractor pulls the fluid towards the cell {X, Y}. The force fades out linearly
+// with the distance and vanishes beyond Radius cells. Negative strengths push the fluid away.
+type Attractor struct {
+	X, Y     float64
+	Radius   float64
+	Strength float64
+}
+
+// Force implements the ForceProvider interface.
+func (a Attractor) Force(x, y int, u, v float64) (fx, fy float64) {
+	dx, dy := a.X-float64(x), a.Y-float64(y)
+	dist := math.Sqrt(dx*dx + dy*dy)
+	if dist == 0 || dist >= a.Radius {
+		return 0, 0
+	}
+	f := a.Strength * (1 - dist/a.Radius) / dist
+	return f * dx, f * dy
+}
+
+// Vortex spins the fluid around the cell {X, Y}. The force fades out linearly with the distance
+// and vanishes beyond Radius cells. Positive strengths spin the fluid clockwise on the screen.
+type Vortex struct {
+	X, Y     float64
+	Radius   float64
+	Strength float64
+}
+
+// Force implements the ForceProvider interface.
+func (vx Vortex) Force(x, y int, u, v float64) (fx, fy float64) {
+	dx, dy := float64(x)-vx.X, float64(y)-vx.Y
+	dist := math.Sqrt(dx*dx + dy*dy)
+	if dist == 0 || dist >= vx.Radius {
+		return 0, 0
+	}
+	f := vx.Strength * (1 - dist/vx.Radius) / dist
+	return -f * dy, f * dx
+}
+
+// AddForceProvider adds a provider of external forces to the solver.
+func (fs *Solver) AddForceProvider(p ForceProvider) {
+	fs.forceProviders = append(fs.forceProviders, p)
+}
+
+// ClearForceProviders removes all the force providers.
+func (fs *Solver) ClearForceProviders() {
+	fs.forceProviders = nil
+}
+
+// SetForce sets the constant force acting on cell {x, y}, where the coordinates range from 1 to N.
+// Unlike the velocity sources, the force is kept between the steps until it's changed or cleared.
+func (fs *Solver) SetForce(x, y int, fx, fy float64) error {
+	if x < 1 || x > fs.nx || y < 1 || y > fs.ny {
+		return fmt.Errorf("%w: (%d, %d)", ErrOutOfBounds, x, y)
+	}
+	if !isFinite(fx) || !isFinite(fy) {
+		return fmt.Errorf("fluid: force must be finite, got (%v, %v)", fx, fy)
+	}
+	if fs.forceU == nil {
+		if fx == 0 && fy == 0 {
+			return nil
+		}
+		fs.forceU = make(cell, fs.numOfCells)
+		fs.forceV = make(cell, fs.numOfCells)
+	}
+	k := fs.idx(x, y)
+	fs.forceU[k], fs.forceV[k] = fx, fy
+
+	return nil
+}
+
+// ForceAt returns the constant force acting on cell {x, y}. The force doesn't include
+// the gravity of the configuration and the forces of the providers.
+func (fs *Solver) ForceAt(x, y int) (fx, fy float64) {
+	if fs.forceU == nil || !fs.inBounds(x, y) {
+		return 0, 0
+	}
+	k := fs.idx(x, y)
+	return fs.forceU[k], fs.forceV[k]
+}
+
+// ClearForces removes the constant forces of all the cells.
+func (fs *Solver) ClearForces() {
+	fs.forceU, fs.forceV = nil, nil
+}
+
+// hasForces checks if any external force acts on the fluid.
+func (fs *Solver) hasForces() bool {
+	return fs.config.GravityX != 0 || fs.config.GravityY != 0 ||
+		fs.forceU != nil || len(fs.forceProviders) > 0
+}
+
+// externalForces sums the gravity, the constant forces of the cells and the forces
+// of the providers into {fu} and {fv}. The solid cells are left without force.
+func (fs *Solver) externalForces(fu, fv cell) {
+	for i := range fu {
+		fu[i], fv[i] = 0, 0
+	}
+	for j := 1; j <= fs.ny; j++ {
+		for i := 1; i <= fs.nx; i++ {
+			k := fs.idx(i, j)
+			if fs.isSolid(k) {
+				continue
+			}
+			fx, fy := fs.config.GravityX, fs.config.GravityY
+			if fs.forceU != nil {
+				fx += fs.forceU[k]
+				fy += fs.forceV[k]
+			}
+			for _, p := range fs.forceProviders {
+				px, py := p.Force(i, j, fs.u[k], fs.v[k])
+				fx += px
+				fy += py
+			}
+			fu[k], fv[k] = fx, fy
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package fluid
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// forceConfig returns a configuration without the built-in forces.
+func forceConfig() SolverConfig {
+	cfg := DefaultConfig()
+	cfg.Vorticity = false
+	cfg.Buoyancy = false
+	return cfg
+}
+
+func TestGravityPeriodic(t *testing.T) {
+	periodic := Edge{Type: EdgePeriodic}
+	cfg := forceConfig()
+	cfg.GravityX, cfg.GravityY = 0.01, -0.02
+	cfg.Edges = Edges{Left: periodic, Right: periodic, Top: periodic, Bottom: periodic}
+
+	fs, err := NewSolverConfig(16, 16, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.VelocityStep()
+
+	// Nothing holds back the fluid on a periodic grid, so it's accelerated uniformly.
+	wantU, wantV := cfg.GravityX*cfg.Dt, cfg.GravityY*cfg.Dt
+	for j := 1; j <= 16; j++ {
+		for i := 1; i <= 16; i++ {
+			k := fs.idx(i, j)
+			if math.Abs(fs.u[k]-wantU) > 1e-9 || math.Abs(fs.v[k]-wantV) > 1e-9 {
+				t.Fatalf("velocity at (%d, %d) is (%v, %v), want (%v, %v)", i, j, fs.u[k], fs.v[k], wantU, wantV)
+			}
+		}
+	}
+}
+
+func TestForceProviders(t *testing.T) {
+	const n = 24
+
+	fs, err := NewSolverConfig(n, n, forceConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.SetObstacle(1, 1, ObstacleNoSlip)
+
+	var calls int
+	fs.AddForceProvider(ForceFunc(func(x, y int, u, v float64) (float64, float64) {
+		calls++
+		return 0, 0
+	}))
+	fs.AddForceProvider(Vortex{X: n / 2, Y: n / 2, Radius: n / 3, Strength: 0.05})
+	fs.VelocityStep()
+
+	if want := n*n - 1; calls != want {
+		t.Errorf("the provider was called %d times, want %d", calls, want)
+	}
+	// The fluid above the center moves to the right and the fluid below it to the left.
+	if above, below := fs.u[fs.idx(n/2, n/2-3)], fs.u[fs.idx(n/2, n/2+3)]; above <= 0 || below >= 0 {
+		t.Errorf("the vortex doesn't spin the fluid clockwise: u above %v, u below %v", above, below)
+	}
+
+	fs.ClearForceProviders()
+	calls = 0
+	fs.VelocityStep()
+	if calls != 0 {
+		t.Errorf("the cleared provider was called %d times", calls)
+	}
+}
+
+func TestAttractor(t *testing.T) {
+	a := Attractor{X: 10, Y: 10, Radius: 5, Strength: 1}
+
+	if fx, fy := a.Force(8, 10, 0, 0); fx <= 0 || fy != 0 {
+		t.Errorf("force left of the attractor is (%v, %v), want it pointing right", fx, fy)
+	}
+	if fx, fy := a.Force(10, 12, 0, 0); fx != 0 || fy >= 0 {
+		t.Errorf("force below the attractor is (%v, %v), want it pointing up", fx, fy)
+	}
+	if fx, fy := a.Force(20, 10, 0, 0); fx != 0 || fy != 0 {
+		t.Errorf("force out of the radius is (%v, %v), want none", fx, fy)
+	}
+}
+
+func TestSetForce(t *testing.T) {
+	fs, err := NewSolverConfig(16, 16, forceConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.SetForce(0, 5, 1, 0); !errors.Is(err, ErrOutOfBounds) {
+		t.Errorf("got %v, want ErrOutOfBounds", err)
+	}
+	if err := fs.SetForce(5, 5, math.NaN(), 0); err == nil {
+		t.Error("expected an error for a NaN force")
+	}
+
+	fs.SetForce(8, 8, 0, 0.1)
+	if fx, fy := fs.ForceAt(8, 8); fx != 0 || fy != 0.1 {
+		t.Errorf("ForceAt returned (%v, %v), want (0, 0.1)", fx, fy)
+	}
+	fs.VelocityStep()
+	if v := fs.v[fs.idx(8, 8)]; v <= 0 {
+		t.Errorf("the force didn't push the fluid down, v = %v", v)
+	}
+	// The force is kept between the steps.
+	fs.VelocityStep()
+	if fx, fy := fs.ForceAt(8, 8); fx != 0 || fy != 0.1 {
+		t.Errorf("the force changed to (%v, %v) after the step", fx, fy)
+	}
+
+	fs.ClearForces()
+	if fx, fy := fs.ForceAt(8, 8); fx != 0 || fy != 0 {
+		t.Errorf("ForceAt returned (%v, %v) after ClearForces", fx, fy)
+	}
+}
@@ -0,0 +1,4 @@
+package fluid
+
+// The fluid32 package is the single precision variant of this package, generated from its sources.
+//go:generate go run ./internal/gen32 -out fluid32
@@ -0,0 +1,110 @@
+// Command gen32 generates the single precision variant of the fluid package. The float64 types
+// of the package sources are replaced with float32 and the math functions with their float32
+// counterparts from the math32 package.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	header     = "// Code generated by gen32 from the fluid package. DO NOT EDIT.\n\n"
+	math32Path = "github.com/esimov/ascii-fluid/fluid-solver/internal/math32"
+)
+
+// literals maps the string literals which differ in the generated package.
+var literals = map[string]string{
+	// The snapshots of the two variants are not compatible.
+	`"FLUD"`: `"FL32"`,
+}
+
+func main() {
+	var (
+		src = flag.String("src", ".", "Directory of the fluid package sources")
+		out = flag.String("out", "fluid32", "Directory of the generated package")
+		pkg = flag.String("pkg", "fluid32", "Name of the generated package")
+	)
+	flag.Parse()
+
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		log.Fatal(err)
+	}
+	// Remove the previously generated files, in case a source file was removed.
+	old, err := filepath.Glob(filepath.Join(*out, "*.go"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, name := range old {
+		if !strings.HasSuffix(name, "_test.go") {
+			if err := os.Remove(name); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(*src, "*.go"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, name := range files {
+		base := filepath.Base(name)
+		if strings.HasSuffix(base, "_test.go") || base == "generate.go" {
+			continue
+		}
+		data, err := generate(name, *pkg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(*out, base), data, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// generate rewrites the source file {name} into the package {pkg}.
+func generate(name, pkg string) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	f.Name.Name = pkg
+
+	for _, imp := range f.Imports {
+		if path, _ := strconv.Unquote(imp.Path.Value); path == "math" {
+			imp.Name = ast.NewIdent("math")
+			imp.Path.Value = strconv.Quote(math32Path)
+		}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Ident:
+			if n.Name == "float64" {
+				n.Name = "float32"
+			}
+		case *ast.BasicLit:
+			if lit, ok := literals[n.Value]; ok {
+				n.Value = lit
+			}
+		}
+		return true
+	})
+
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	if err := format.Node(&buf, fset, f); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
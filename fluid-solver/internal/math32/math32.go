@@ -0,0 +1,60 @@
+// Package math32 provides the float32 variants of the math functions used by the fluid solver.
+package math32
+
+import "math"
+
+// Abs returns the absolute value of {x}.
+func Abs(x float32) float32 {
+	return math.Float32frombits(math.Float32bits(x) &^ (1 << 31))
+}
+
+// Ceil returns the least integer value greater than or equal to {x}.
+func Ceil(x float32) float32 {
+	return float32(math.Ceil(float64(x)))
+}
+
+// Exp returns e**{x}, the base-e exponential of {x}.
+func Exp(x float32) float32 {
+	return float32(math.Exp(float64(x)))
+}
+
+// Floor returns the greatest integer value less than or equal to {x}.
+func Floor(x float32) float32 {
+	return float32(math.Floor(float64(x)))
+}
+
+// IsInf reports whether {x} is an infinity, according to {sign}, like math.IsInf.
+func IsInf(x float32, sign int) bool {
+	return math.IsInf(float64(x), sign)
+}
+
+// IsNaN reports whether {x} is a NaN value.
+func IsNaN(x float32) bool {
+	return x != x
+}
+
+// Max returns the larger of {x} and {y}. Unlike math.Max, it doesn't handle the special cases.
+func Max(x, y float32) float32 {
+	if x > y {
+		return x
+	}
+	return y
+}
+
+// Min returns the smaller of {x} and {y}. Unlike math.Min, it doesn't handle the special cases.
+func Min(x, y float32) float32 {
+	if x < y {
+		return x
+	}
+	return y
+}
+
+// Mod returns the floating-point remainder of {x}/{y}, with the sign of {x}.
+func Mod(x, y float32) float32 {
+	return float32(math.Mod(float64(x), float64(y)))
+}
+
+// Sqrt returns the square root of {x}.
+func Sqrt(x float32) float32 {
+	return float32(math.Sqrt(float64(x)))
+}
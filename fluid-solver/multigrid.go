@@ -0,0 +1,173 @@
+package fluid
+
+// Multigrid is the pressure solver running geometric multigrid V-cycles. Every level
+// halves the grid resolution, the levels are smoothed with red-black Gauss-Seidel
+// sweeps and the coarse grid corrections are interpolated bilinearly.
+// A solver value should not be shared between fluid solvers running concurrently.
+type Multigrid struct {
+	// Tolerance stops the iteration once the residual drops below it.
+	Tolerance float64
+	// MaxIterations limits the number of V-cycles. Zero means SolverConfig.Iterations.
+	MaxIterations int
+	// Smoothing is the number of sweeps before and after the coarse grid correction. Zero means 2.
+	Smoothing int
+
+	levels []mgLevel
+}
+
+// mgLevel holds the unknowns, the right hand side and the residuals of a multigrid level.
+type mgLevel struct {
+	g       grid
+	p, b, r cell
+}
+
+const (
+	// mgCoarsest is the grid size below which the grid is not coarsened further.
+	mgCoarsest = 4
+	// mgCoarseSweeps is the number of relaxation sweeps solving the coarsest level.
+	mgCoarseSweeps = 50
+)
+
+// Solve implements the PressureSolver interface.
+func (mg *Multigrid) Solve(fs *Solver, p, div []float64) (int, float64) {
+	g := fs.grid()
+	maxIter := mg.MaxIterations
+	if maxIter <= 0 {
+		maxIter = fs.config.Iterations
+	}
+	mg.build(g)
+	mg.levels[0].p, mg.levels[0].b = p, div
+
+	g.boundary(p)
+	residual := g.residual(p, div, nil)
+	for it := 1; it <= maxIter; it++ {
+		if residual <= mg.Tolerance {
+			return it - 1, residual
+		}
+		mg.vcycle(0)
+		residual = g.residual(p, div, nil)
+	}
+	return maxIter, residual
+}
+
+// build allocates the levels of the multigrid hierarchy for the grid {g}.
+// A coarse cell is solid when all the fine cells it covers are solid.
+func (mg *Multigrid) build(g grid) {
+	if len(mg.levels) == 0 || mg.levels[0].g.nx != g.nx || mg.levels[0].g.ny != g.ny {
+		mg.levels = []mgLevel{{g: g, r: make(cell, g.size())}}
+		for c := g; c.nx > mgCoarsest && c.ny > mgCoarsest; {
+			c = grid{nx: (c.nx + 1) / 2, ny: (c.ny + 1) / 2}
+			mg.levels = append(mg.levels, mgLevel{
+				g: c,
+				p: make(cell, c.size()),
+				b: make(cell, c.size()),
+				r: make(cell, c.size()),
+			})
+		}
+	}
+	mg.levels[0].g = g
+
+	for l := 1; l < len(mg.levels); l++ {
+		fine, coarse := mg.levels[l-1].g, &mg.levels[l].g
+		coarse.workers = g.workers
+		coarse.edges = g.edges
+		if fine.solid == nil {
+			coarse.solid = nil
+			continue
+		}
+		if len(coarse.solid) != coarse.size() {
+			coarse.solid = make([]Obstacle, coarse.size())
+		}
+		for J := 1; J <= coarse.ny; J++ {
+			for I := 1; I <= coarse.nx; I++ {
+				o := ObstacleNoSlip
+				for j := 2*J - 1; j <= 2*J && j <= fine.ny; j++ {
+					for i := 2*I - 1; i <= 2*I && i <= fine.nx; i++ {
+						if fine.isFluid(i, j) {
+							o = ObstacleNone
+						}
+					}
+				}
+				coarse.solid[coarse.idx(I, J)] = o
+			}
+		}
+	}
+}
+
+// vcycle runs a multigrid V-cycle starting from level {l}.
+func (mg *Multigrid) vcycle(l int) {
+	lv := &mg.levels[l]
+	if l == len(mg.levels)-1 {
+		for k := 0; k < mgCoarseSweeps; k++ {
+			lv.g.relax(lv.p, lv.b)
+			lv.g.boundary(lv.p)
+		}
+		return
+	}
+
+	smoothing := mg.Smoothing
+	if smoothing <= 0 {
+		smoothing = 2
+	}
+	for k := 0; k < smoothing; k++ {
+		lv.g.relax(lv.p, lv.b)
+		lv.g.boundary(lv.p)
+	}
+	lv.g.residual(lv.p, lv.b, lv.r)
+
+	coarse := &mg.levels[l+1]
+	mg.restrict(lv, coarse)
+	mg.vcycle(l + 1)
+	mg.prolong(coarse, lv)
+	lv.g.boundary(lv.p)
+
+	for k := 0; k < smoothing; k++ {
+		lv.g.relax(lv.p, lv.b)
+		lv.g.boundary(lv.p)
+	}
+}
+
+// restrict transfers the residuals of the {fine} level into the right hand side of the {coarse}
+// level. Doubling the cell size quadruples the right hand side, so the residuals of the fine
+// cells covered by a coarse cell are summed up instead of averaged.
+func (mg *Multigrid) restrict(fine, coarse *mgLevel) {
+	fg, cg := fine.g, coarse.g
+	for J := 1; J <= cg.ny; J++ {
+		for I := 1; I <= cg.nx; I++ {
+			var sum float64
+			for j := 2*J - 1; j <= 2*J && j <= fg.ny; j++ {
+				for i := 2*I - 1; i <= 2*I && i <= fg.nx; i++ {
+					sum += fine.r[fg.idx(i, j)]
+				}
+			}
+			coarse.b[cg.idx(I, J)] = sum
+			coarse.p[cg.idx(I, J)] = 0
+		}
+	}
+	cg.boundary(coarse.p)
+}
+
+// prolong interpolates the {coarse} level correction bilinearly and adds it to the {fine} level.
+func (mg *Multigrid) prolong(coarse, fine *mgLevel) {
+	fg, cg := fine.g, coarse.g
+	rows(fg.ny, fg.workers, func(j0, j1 int) {
+		for j := j0; j <= j1; j++ {
+			J := (j + 1) / 2
+			// The other coarse row is on the side of the fine cell's center.
+			J2 := J + 1
+			if j%2 == 1 {
+				J2 = J - 1
+			}
+			for i := 1; i <= fg.nx; i++ {
+				I := (i + 1) / 2
+				I2 := I + 1
+				if i%2 == 1 {
+					I2 = I - 1
+				}
+				fine.p[fg.idx(i, j)] += 0.5625*coarse.p[cg.idx(I, J)] +
+					0.1875*(coarse.p[cg.idx(I2, J)]+coarse.p[cg.idx(I, J2)]) +
+					0.0625*coarse.p[cg.idx(I2, J2)]
+			}
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package fluid
+
+import "fmt"
+
+// Obstacle defines whether a cell is solid and how the fluid flows along its walls.
+type Obstacle uint8
+
+const (
+	// ObstacleNone marks a fluid cell.
+	ObstacleNone Obstacle = iota
+	// ObstacleNoSlip marks a solid cell which stops the fluid flowing along its walls.
+	ObstacleNoSlip
+	// ObstacleFreeSlip marks a solid cell which lets the fluid slide along its walls.
+	ObstacleFreeSlip
+)
+
+// SetObstacle sets the obstacle type of cell {x, y}, where the coordinates range from 1 to N.
+func (fs *Solver) SetObstacle(x, y int, o Obstacle) error {
+	if x < 1 || x > fs.nx || y < 1 || y > fs.ny {
+		return fmt.Errorf("%w: (%d, %d)", ErrOutOfBounds, x, y)
+	}
+	if o > ObstacleFreeSlip {
+		return fmt.Errorf("fluid: unknown obstacle type %d", o)
+	}
+	if fs.obstacles == nil {
+		if o == ObstacleNone {
+			return nil
+		}
+		fs.obstacles = make([]Obstacle, fs.numOfCells)
+	}
+	fs.obstacles[fs.idx(x, y)] = o
+
+	return nil
+}
+
+// ObstacleAt returns the obstacle type of cell {x, y}. The cells outside of the grid are fluid cells.
+func (fs *Solver) ObstacleAt(x, y int) Obstacle {
+	if fs.obstacles == nil || !fs.inBounds(x, y) {
+		return ObstacleNone
+	}
+	return fs.obstacles[fs.idx(x, y)]
+}
+
+// ClearObstacles removes all the obstacles.
+func (fs *Solver) ClearObstacles() {
+	fs.obstacles = nil
+}
+
+// isSolid checks if the cell at index {k} is part of an obstacle.
+func (fs *Solver) isSolid(k int) bool {
+	return fs.obstacles != nil && fs.obstacles[k] != ObstacleNone
+}
+
+// setObstacleBoundary sets the values of the solid cells from their fluid neighbours. The velocity
+// component normal to a wall is mirrored, so the fluid doesn't flow through it. The tangential
+// component is mirrored along the no-slip walls and copied along the free-slip walls.
+func (fs *Solver) setObstacleBoundary(bound BoundaryType, x cell) {
+	neighbours := [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+
+	for j := 1; j <= fs.ny; j++ {
+		for i := 1; i <= fs.nx; i++ {
+			o := fs.obstacles[fs.idx(i, j)]
+			if o == ObstacleNone {
+				continue
+			}
+
+			var sum, n float64
+			for _, nb := range neighbours {
+				ni, nj := i+nb[0], j+nb[1]
+				if ni < 1 || ni > fs.nx || nj < 1 || nj > fs.ny || fs.obstacles[fs.idx(ni, nj)] != ObstacleNone {
+					continue
+				}
+				normal := (bound == BoundaryLeftRight && nb[0] != 0) || (bound == BoundaryTopBottom && nb[1] != 0)
+				tangent := (bound == BoundaryLeftRight && nb[1] != 0) || (bound == BoundaryTopBottom && nb[0] != 0)
+
+				if normal || (tangent && o == ObstacleNoSlip) {
+					sum -= x[fs.idx(ni, nj)]
+				} else {
+					sum += x[fs.idx(ni, nj)]
+				}
+				n++
+			}
+			if n > 0 {
+				x[fs.idx(i, j)] = sum / n
+			} else {
+				x[fs.idx(i, j)] = 0
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package fluid
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestObstacleFlow(t *testing.T) {
+	const (
+		nx, ny = 32, 16
+		// The wall block spans the cells from {i0, j0} to {i1, j1}.
+		i0, i1, j0, j1 = 10, 12, 5, 12
+	)
+	for _, o := range []Obstacle{ObstacleNoSlip, ObstacleFreeSlip} {
+		solvers := []PressureSolver{
+			&GaussSeidel{Tolerance: 1e-6, MaxIterations: 20000},
+			&ConjugateGradient{Tolerance: 1e-6},
+			&Multigrid{Tolerance: 1e-6, MaxIterations: 50},
+		}
+		for _, ps := range solvers {
+			name := fmt.Sprintf("obstacle %d, %T", o, ps)
+			cfg := DefaultConfig()
+			cfg.Edges = Edges{Left: Edge{Type: EdgeInflow, U: 0.01}, Right: Edge{Type: EdgeOutflow}}
+			fs, err := NewSolverConfig(nx, ny, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fs.SetPressureSolver(ps)
+			for j := j0; j <= j1; j++ {
+				for i := i0; i <= i1; i++ {
+					if err := fs.SetObstacle(i, j, o); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			fs.Set(FieldDOld, 4, 8, 50)
+
+			for step := 0; step < 40; step++ {
+				fs.Step(0.2)
+			}
+			if err := fs.CheckFinite(); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if _, residual := fs.PressureStats(); residual > 1e-6 {
+				t.Errorf("%s: the pressure solve didn't converge, got residual %v", name, residual)
+			}
+
+			// The cells inside the wall keep zero velocity, and the fluid doesn't flow through its sides.
+			for j := j0 + 1; j < j1; j++ {
+				k := fs.idx(i0+1, j)
+				if fs.u[k] != 0 || fs.v[k] != 0 {
+					t.Errorf("%s: the solid cell (%d, %d) has velocity (%v, %v)", name, i0+1, j, fs.u[k], fs.v[k])
+				}
+				for _, c := range [][2]int{{i0 - 1, i0}, {i1 + 1, i1}} {
+					if flux := fs.u[fs.idx(c[0], j)] + fs.u[fs.idx(c[1], j)]; flux != 0 {
+						t.Errorf("%s: the fluid flows through the wall at (%d, %d), got %v", name, c[1], j, flux/2)
+					}
+				}
+			}
+
+			// The fluid flows around the wall.
+			if u, _ := fs.SampleVelocity((float64(i1)+4.5)/nx, 0.5/ny); u <= 0 {
+				t.Errorf("%s: the fluid doesn't pass the wall, got velocity %v", name, u)
+			}
+		}
+	}
+}
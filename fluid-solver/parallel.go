@@ -0,0 +1,36 @@
+package fluid
+
+import "sync"
+
+// parallel splits the grid rows into bands of equal height and calls {fn} with the
+// first and the last row of each band. The bands are processed concurrently by
+// the configured number of workers, or on the calling goroutine for a single worker.
+func (fs *Solver) parallel(fn func(j0, j1 int)) {
+	rows(fs.ny, fs.config.Workers, fn)
+}
+
+// rows splits the rows [1, ny] into bands shared between {workers} goroutines.
+func rows(ny, workers int, fn func(j0, j1 int)) {
+	if workers > ny {
+		workers = ny
+	}
+	if workers <= 1 {
+		fn(1, ny)
+		return
+	}
+
+	var wg sync.WaitGroup
+	band := (ny + workers - 1) / workers
+	for j0 := 1; j0 <= ny; j0 += band {
+		j1 := j0 + band - 1
+		if j1 > ny {
+			j1 = ny
+		}
+		wg.Add(1)
+		go func(j0, j1 int) {
+			defer wg.Done()
+			fn(j0, j1)
+		}(j0, j1)
+	}
+	wg.Wait()
+}
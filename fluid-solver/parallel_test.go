@@ -0,0 +1,69 @@
+package fluid
+
+import (
+	"fmt"
+	"testing"
+)
+
+// newBenchSolver creates a solver with a dense velocity and density field.
+func newBenchSolver(tb testing.TB, n, workers int) *Solver {
+	cfg := DefaultConfig()
+	cfg.Workers = workers
+	fs, err := NewSolverConfig(n, n, cfg)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for j := 1; j <= n; j++ {
+		for i := 1; i <= n; i++ {
+			fs.uOld[fs.idx(i, j)] = float64((i*7+j*3)%11) - 5
+			fs.vOld[fs.idx(i, j)] = float64((i*5+j*13)%7) - 3
+			fs.dOld[fs.idx(i, j)] = float64((i + j) % 17)
+		}
+	}
+	return fs
+}
+
+func TestParallelDeterministic(t *testing.T) {
+	ref := newBenchSolver(t, 64, 1)
+	for step := 0; step < 5; step++ {
+		ref.VelocityStep()
+		ref.DensityStep()
+	}
+
+	for _, workers := range []int{2, 3, 8} {
+		fs := newBenchSolver(t, 64, workers)
+		for step := 0; step < 5; step++ {
+			fs.VelocityStep()
+			fs.DensityStep()
+		}
+		for i := range ref.d {
+			if fs.d[i] != ref.d[i] || fs.u[i] != ref.u[i] || fs.v[i] != ref.v[i] {
+				t.Fatalf("workers=%d: cell %d differs from the single worker result", workers, i)
+			}
+		}
+	}
+}
+
+func BenchmarkVelocityStep256(b *testing.B) {
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			fs := newBenchSolver(b, 256, workers)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				fs.VelocityStep()
+			}
+		})
+	}
+}
+
+func BenchmarkDensityStep256(b *testing.B) {
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			fs := newBenchSolver(b, 256, workers)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				fs.DensityStep()
+			}
+		})
+	}
+}
@@ -1,11 +1,21 @@
 package fluid
 
+import (
+	"math"
+	"math/rand"
+)
+
 // Particle defines the general components of the particle system.
 type Particle struct {
 	x, y   float64
 	vx, vy float64
 	age    float64
 	dead   bool
+
+	life  float64
+	color [3]float64
+	size  float64
+	mass  float64
 }
 
 // NewParticle spawns a new particle at coordinates defined by {x, y}.
@@ -72,3 +82,273 @@ func (p *Particle) GetDeath() bool {
 func (p *Particle) SetDeath(dead bool) {
 	p.dead = dead
 }
+
+// GetLife get the particle lifetime.
+func (p *Particle) GetLife() float64 {
+	return p.life
+}
+
+// SetLife set the particle lifetime. The particle system kills the particle once its age reaches the lifetime.
+func (p *Particle) SetLife(life float64) {
+	p.life = life
+}
+
+// GetColor get the particle color.
+func (p *Particle) GetColor() [3]float64 {
+	return p.color
+}
+
+// SetColor set the particle color.
+func (p *Particle) SetColor(color [3]float64) {
+	p.color = color
+}
+
+// GetSize get the particle size.
+func (p *Particle) GetSize() float64 {
+	return p.size
+}
+
+// SetSize set the particle size.
+func (p *Particle) SetSize(size float64) {
+	p.size = size
+}
+
+// GetMass get the particle mass.
+func (p *Particle) GetMass() float64 {
+	return p.mass
+}
+
+// SetMass set the particle mass. The particles without mass are passive tracers, even when coupled to the flow.
+func (p *Particle) SetMass(mass float64) {
+	p.mass = mass
+}
+
+// FlowSampler moves the particles along a flow. The Solver implements it, using the normalized
+// coordinates of SampleVelocity.
+type FlowSampler interface {
+	// Trace moves the point {x, y} along the flow over {dt} time.
+	Trace(x, y, dt float64) (float64, float64)
+}
+
+// FlowCoupler is a flow the particles can push back. The Solver implements it.
+type FlowCoupler interface {
+	FlowSampler
+	// FlowVelocity returns the velocity of the flow at {x, y}, in the coordinates of the particles per unit of time.
+	FlowVelocity(x, y float64) (vx, vy float64)
+	// Deposit adds the force {fx, fy} and the density rate {density} to the flow at {x, y}.
+	Deposit(x, y, fx, fy, density float64)
+}
+
+// Coupling defines the two-way coupling of the particles with the flow. The coupled particles with
+// mass are dragged by the flow and pulled by the gravity, while the flow gets the opposite of the drag.
+type Coupling struct {
+	// Drag is the drag coefficient. The lighter particles follow the flow faster than the heavy ones.
+	Drag float64
+	// GravityX and GravityY are the components of the gravity acting on the particles.
+	GravityX, GravityY float64
+	// Density is the density rate each particle deposits into the flow.
+	Density float64
+}
+
+// ParticleEmitter spawns particles into a particle system. The positions are given in the
+// coordinates of the flow sampler, and the times in the time units of ParticleSystem.Update.
+type ParticleEmitter struct {
+	// X and Y are the position of the emitter center.
+	X, Y float64
+	// Spread is the size of the square around the center the particles are spawned in.
+	Spread float64
+	// Rate is the number of the particles spawned per unit of time.
+	Rate float64
+	// Lifetime is the time the spawned particles live for.
+	Lifetime float64
+	// VX and VY are the initial velocity of the spawned particles.
+	VX, VY float64
+	// Color and Size are the color and the size of the spawned particles.
+	Color [3]float64
+	Size  float64
+	// Mass is the mass of the spawned particles, relative to the fluid in a grid cell.
+	Mass float64
+
+	// pending is the fraction of a particle carried over to the next update.
+	pending float64
+}
+
+// ParticleSystem moves particles along a flow. The particles are spawned by the emitters,
+// and they die once they reach their lifetime or leave the unit square, which is covered by
+// the grid in the normalized coordinates of the solver. The dead particles are kept in a pool
+// and reused by the next spawns.
+type ParticleSystem struct {
+	max       int
+	particles []*Particle
+	pool      []*Particle
+	emitters  []*ParticleEmitter
+	rnd       *rand.Rand
+	coupling  *Coupling
+}
+
+// NewParticleSystem creates a particle system holding at most {max} particles. The particle
+// positions around the emitters are randomized with the random source seeded by {seed}.
+func NewParticleSystem(max int, seed int64) *ParticleSystem {
+	return &ParticleSystem{
+		max: max,
+		rnd: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Particles returns the living particles. The slice is only valid until the next update.
+func (ps *ParticleSystem) Particles() []*Particle {
+	return ps.particles
+}
+
+// Len returns the number of the living particles.
+func (ps *ParticleSystem) Len() int {
+	return len(ps.particles)
+}
+
+// Max returns the maximum number of the particles.
+func (ps *ParticleSystem) Max() int {
+	return ps.max
+}
+
+// AddEmitter adds a particle emitter to the system. The emitter is kept by reference,
+// so its fields can be changed later, e.g. to move it around.
+func (ps *ParticleSystem) AddEmitter(e *ParticleEmitter) {
+	ps.emitters = append(ps.emitters, e)
+}
+
+// RemoveEmitter removes the particle emitter {e} from the system.
+func (ps *ParticleSystem) RemoveEmitter(e *ParticleEmitter) {
+	for i, em := range ps.emitters {
+		if em == e {
+			ps.emitters = append(ps.emitters[:i], ps.emitters[i+1:]...)
+			return
+		}
+	}
+}
+
+// Spawn adds {n} particles of the emitter {e} at once, as long as the system is not full.
+// It returns the number of the particles spawned.
+func (ps *ParticleSystem) Spawn(e *ParticleEmitter, n int) int {
+	if free := ps.max - len(ps.particles); n > free {
+		n = free
+	}
+	if n <= 0 {
+		return 0
+	}
+	for i := 0; i < n; i++ {
+		var p *Particle
+		if l := len(ps.pool); l > 0 {
+			p, ps.pool = ps.pool[l-1], ps.pool[:l-1]
+		} else {
+			p = &Particle{}
+		}
+		*p = Particle{
+			x:     e.X + e.Spread*(float64(ps.rnd.Float64())-0.5),
+			y:     e.Y + e.Spread*(float64(ps.rnd.Float64())-0.5),
+			vx:    e.VX,
+			vy:    e.VY,
+			life:  e.Lifetime,
+			color: e.Color,
+			size:  e.Size,
+			mass:  e.Mass,
+		}
+		ps.particles = append(ps.particles, p)
+	}
+	return n
+}
+
+// SetCoupling enables the two-way coupling of the particles with the flow, as defined by {c}.
+// The coupling only applies when the flow of Update is a FlowCoupler. Nil disables the coupling,
+// so the particles are moved along the flow as passive tracers.
+func (ps *ParticleSystem) SetCoupling(c *Coupling) {
+	ps.coupling = c
+}
+
+// Coupling returns the coupling of the particles with the flow, or nil if the coupling is disabled.
+func (ps *ParticleSystem) Coupling() *Coupling {
+	return ps.coupling
+}
+
+// Clear kills all the particles.
+func (ps *ParticleSystem) Clear() {
+	for _, p := range ps.particles {
+		ps.pool = append(ps.pool, p)
+	}
+	ps.particles = ps.particles[:0]
+}
+
+// Update advances the particle system by {dt}. The emitters spawn their new particles,
+// then all the particles age and move along the flow of {sampler}. The particles which
+// reached their lifetime or left the unit square are removed and put back into the pool.
+// The coupled particles with mass collide with the edges of the unit square instead of leaving it.
+func (ps *ParticleSystem) Update(dt float64, sampler FlowSampler) {
+	coupler, coupled := sampler.(FlowCoupler)
+	coupled = coupled && ps.coupling != nil
+
+	for _, e := range ps.emitters {
+		e.pending += e.Rate * dt
+		n := int(e.pending)
+		e.pending -= float64(n)
+		ps.Spawn(e, n)
+	}
+
+	alive := ps.particles[:0]
+	for _, p := range ps.particles {
+		p.age += dt
+		if p.age >= p.life {
+			p.dead = true
+		} else if coupled && p.mass > 0 && dt > 0 {
+			ps.couple(p, dt, coupler)
+		} else if dt > 0 {
+			if coupled && ps.coupling.Density != 0 {
+				coupler.Deposit(p.x, p.y, 0, 0, ps.coupling.Density)
+			}
+			x, y := sampler.Trace(p.x, p.y, dt)
+			p.vx, p.vy = (x-p.x)/dt, (y-p.y)/dt
+			p.x, p.y = x, y
+			p.dead = !(x >= 0 && x <= 1 && y >= 0 && y <= 1)
+		}
+		if p.dead {
+			ps.pool = append(ps.pool, p)
+			continue
+		}
+		alive = append(alive, p)
+	}
+	for i := len(alive); i < len(ps.particles); i++ {
+		ps.particles[i] = nil
+	}
+	ps.particles = alive
+}
+
+// couple moves the particle {p} with mass over {dt}, exchanging the drag force with the flow.
+// The drag is integrated implicitly, so the heavy drag coefficients don't make it unstable.
+// The particle dies instead if it doesn't have a finite position and velocity.
+func (ps *ParticleSystem) couple(p *Particle, dt float64, flow FlowCoupler) {
+	c := ps.coupling
+	u, v := flow.FlowVelocity(p.x, p.y)
+
+	k := c.Drag / p.mass
+	vx := (p.vx + dt*(k*u+c.GravityX)) / (1 + dt*k)
+	vy := (p.vy + dt*(k*v+c.GravityY)) / (1 + dt*k)
+	if !isFinite(p.x) || !isFinite(p.y) || !isFinite(vx) || !isFinite(vy) {
+		// Don't feed the blown up force back into the flow either.
+		p.dead = true
+		return
+	}
+
+	// The flow gets the opposite of the drag force acting on the particle.
+	fx := p.mass * ((vx-p.vx)/dt - c.GravityX)
+	fy := p.mass * ((vy-p.vy)/dt - c.GravityY)
+	flow.Deposit(p.x, p.y, -fx, -fy, c.Density)
+
+	p.x, p.y = p.x+dt*vx, p.y+dt*vy
+	p.vx, p.vy = vx, vy
+
+	// Collide with the edges, so the particles settle on them.
+	if p.x < 0 || p.x > 1 {
+		p.x, p.vx = math.Min(math.Max(p.x, 0), 1), 0
+	}
+	if p.y < 0 || p.y > 1 {
+		p.y, p.vy = math.Min(math.Max(p.y, 0), 1), 0
+	}
+}
@@ -0,0 +1,189 @@
+package fluid
+
+import (
+	"math"
+	"testing"
+)
+
+// uniformFlow moves the particles with a constant velocity.
+type uniformFlow struct{ u, v float64 }
+
+func (f uniformFlow) Trace(x, y, dt float64) (float64, float64) {
+	return x + f.u*dt, y + f.v*dt
+}
+
+func TestParticleLifecycle(t *testing.T) {
+	ps := NewParticleSystem(100, 1)
+	e := &ParticleEmitter{X: 0.5, Y: 0.5, Spread: 0.1, Rate: 8, Lifetime: 1, Color: [3]float64{1, 0.5, 0}, Size: 2}
+	ps.AddEmitter(e)
+
+	ps.Update(0.25, uniformFlow{})
+	if ps.Len() != 2 {
+		t.Fatalf("got %d particles after the first update, want 2", ps.Len())
+	}
+	for _, p := range ps.Particles() {
+		if p.GetColor() != e.Color || p.GetSize() != 2 || p.GetLife() != 1 {
+			t.Errorf("the particle doesn't have the properties of its emitter: %+v", *p)
+		}
+		if p.GetX() < 0.45 || p.GetX() > 0.55 || p.GetY() < 0.45 || p.GetY() > 0.55 {
+			t.Errorf("the particle spawned at (%v, %v), out of the emitter spread", p.GetX(), p.GetY())
+		}
+	}
+
+	// The particles age in the update spawning them as well, so they are
+	// kept for 3 updates and the emitter keeps 6 of them alive.
+	for i := 0; i < 10; i++ {
+		ps.Update(0.25, uniformFlow{})
+		for _, p := range ps.Particles() {
+			if p.GetAge() >= p.GetLife() || p.GetDeath() {
+				t.Fatalf("a dead particle is still in the system: %+v", *p)
+			}
+		}
+	}
+	if ps.Len() != 6 {
+		t.Errorf("got %d particles in the steady state, want 6", ps.Len())
+	}
+
+	ps.RemoveEmitter(e)
+	for i := 0; i < 4; i++ {
+		ps.Update(0.25, uniformFlow{})
+	}
+	if ps.Len() != 0 {
+		t.Errorf("got %d particles after the emitter was removed, want none", ps.Len())
+	}
+}
+
+func TestParticleRemoval(t *testing.T) {
+	ps := NewParticleSystem(10, 1)
+	left := &ParticleEmitter{X: 0.05, Y: 0.5, Lifetime: 10}
+	right := &ParticleEmitter{X: 0.95, Y: 0.5, Lifetime: 10}
+
+	// Neighbouring particles dying in the same update must all be removed.
+	ps.Spawn(left, 3)
+	ps.Spawn(right, 2)
+	ps.Spawn(left, 2)
+	ps.Update(1, uniformFlow{u: -0.1})
+	if ps.Len() != 2 {
+		t.Fatalf("got %d particles, want the 2 particles of the right emitter", ps.Len())
+	}
+	for _, p := range ps.Particles() {
+		if math.Abs(p.GetX()-0.85) > 1e-12 || math.Abs(p.GetVx()+0.1) > 1e-12 || p.GetVy() != 0 {
+			t.Errorf("the particle moved to %v with velocity (%v, %v), want 0.85 and (-0.1, 0)", p.GetX(), p.GetVx(), p.GetVy())
+		}
+	}
+}
+
+func TestParticlePool(t *testing.T) {
+	ps := NewParticleSystem(4, 1)
+	e := &ParticleEmitter{X: 0.5, Y: 0.5, Lifetime: 1, VX: 0.2, VY: -0.3}
+
+	if n := ps.Spawn(e, 10); n != 4 || ps.Len() != 4 {
+		t.Fatalf("spawned %d particles with %d alive, want the cap of 4", n, ps.Len())
+	}
+	if p := ps.Particles()[0]; p.GetVx() != 0.2 || p.GetVy() != -0.3 {
+		t.Errorf("the initial velocity is (%v, %v), want (0.2, -0.3)", p.GetVx(), p.GetVy())
+	}
+	used := make(map[*Particle]bool)
+	for _, p := range ps.Particles() {
+		used[p] = true
+	}
+
+	ps.Update(1, uniformFlow{})
+	if ps.Len() != 0 {
+		t.Fatalf("got %d particles after their lifetime, want none", ps.Len())
+	}
+	ps.Spawn(e, 4)
+	for _, p := range ps.Particles() {
+		if !used[p] {
+			t.Error("a new particle was allocated instead of reusing the pool")
+		}
+		if p.GetAge() != 0 || p.GetDeath() {
+			t.Errorf("the reused particle wasn't reset: %+v", *p)
+		}
+	}
+}
+
+func TestParticleCouplingMomentum(t *testing.T) {
+	const dt = 0.1
+
+	fs := NewSolver(16)
+	ps := NewParticleSystem(10, 1)
+	ps.SetCoupling(&Coupling{Drag: 2, Density: 3})
+	ps.Spawn(&ParticleEmitter{X: 0.43, Y: 0.61, VX: 0.2, VY: -0.1, Lifetime: 10, Mass: 0.5}, 1)
+
+	ps.Update(dt, fs)
+	p := ps.Particles()[0]
+	if p.GetVx() >= 0.2 || p.GetVy() <= -0.1 {
+		t.Errorf("the still fluid didn't slow down the particle, got velocity (%v, %v)", p.GetVx(), p.GetVy())
+	}
+
+	// The momentum lost by the particle is gained by the fluid in the next step.
+	var u, v, d float64
+	for k := range fs.uOld {
+		u, v, d = u+fs.uOld[k], v+fs.vOld[k], d+fs.dOld[k]
+	}
+	if gu := 0.5 * (0.2 - p.GetVx()); math.Abs(u*dt-gu) > 1e-12 {
+		t.Errorf("the fluid gained %v momentum along x, want %v", u*dt, gu)
+	}
+	if gv := 0.5 * (-0.1 - p.GetVy()); math.Abs(v*dt-gv) > 1e-12 {
+		t.Errorf("the fluid gained %v momentum along y, want %v", v*dt, gv)
+	}
+	if math.Abs(d-3) > 1e-12 {
+		t.Errorf("the particle deposited %v density, want 3", d)
+	}
+}
+
+func TestParticleCouplingSettle(t *testing.T) {
+	fs := NewSolver(16)
+	ps := NewParticleSystem(10, 1)
+	ps.SetCoupling(&Coupling{Drag: 1, GravityY: 0.05})
+	ps.Spawn(&ParticleEmitter{X: 0.5, Y: 0.2, Lifetime: 100, Mass: 1}, 1)
+	ps.Spawn(&ParticleEmitter{X: 0.5, Y: 0.2, Lifetime: 100}, 1)
+
+	for i := 0; i < 200; i++ {
+		ps.Update(0.1, fs)
+		fs.VelocityStep()
+	}
+	var heavy, passive int
+	for _, p := range ps.Particles() {
+		if p.GetMass() == 0 {
+			passive++
+			continue
+		}
+		heavy++
+		if p.GetY() != 1 || p.GetVy() != 0 {
+			t.Errorf("the heavy particle didn't settle at the bottom, got y=%v and vy=%v", p.GetY(), p.GetVy())
+		}
+	}
+	if heavy != 1 || passive != 1 {
+		t.Errorf("got %d heavy and %d passive particles, want one of each", heavy, passive)
+	}
+}
+
+func TestParticleNonFinite(t *testing.T) {
+	ps := NewParticleSystem(10, 1)
+	ps.Spawn(&ParticleEmitter{X: 0.5, Y: 0.5, Lifetime: 10}, 2)
+	ps.Update(0.1, uniformFlow{u: math.NaN()})
+	if ps.Len() != 0 {
+		t.Errorf("got %d particles traced to NaN, want none", ps.Len())
+	}
+}
+
+func TestParticleCouplingNonFinite(t *testing.T) {
+	fs := NewSolver(16)
+	for k := range fs.u {
+		fs.u[k] = math.NaN()
+	}
+	ps := NewParticleSystem(10, 1)
+	ps.SetCoupling(&Coupling{Drag: 1, Density: 1})
+	ps.Spawn(&ParticleEmitter{X: 0.5, Y: 0.5, Lifetime: 10, Mass: 1}, 2)
+	ps.Update(0.1, fs)
+	if ps.Len() != 0 {
+		t.Errorf("got %d coupled particles in the NaN flow, want none", ps.Len())
+	}
+	for k := range fs.uOld {
+		if !isFinite(fs.uOld[k]) || !isFinite(fs.vOld[k]) {
+			t.Fatalf("the dying particles deposited the force (%v, %v)", fs.uOld[k], fs.vOld[k])
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package fluid
+
+import "math"
+
+// PressureSolver solves the pressure Poisson equation of the projection step.
+//
+// The discrete equation is 4*p(i,j) - p(i-1,j) - p(i+1,j) - p(i,j-1) - p(i,j+1) = div(i,j)
+// for every fluid cell, with zero pressure gradient across the walls. Both {p} and {div}
+// use the layout of the solver fields (see Solver.Index) and {p} holds the initial guess.
+// Solve returns the number of iterations and the final residual, which is the largest
+// absolute difference between the two sides of the equation.
+type PressureSolver interface {
+	Solve(fs *Solver, p, div []float64) (iterations int, residual float64)
+}
+
+// GaussSeidel is the pressure solver running red-black Gauss-Seidel relaxation sweeps.
+// The zero value runs a fixed number of sweeps, as defined by SolverConfig.Iterations.
+type GaussSeidel struct {
+	// Tolerance stops the relaxation once the residual drops below it.
+	Tolerance float64
+	// MaxIterations limits the number of sweeps. Zero means SolverConfig.Iterations.
+	MaxIterations int
+}
+
+// ConjugateGradient is the pressure solver using the conjugate gradient method
+// preconditioned with the modified incomplete Cholesky factorization, MIC(0).
+// A solver value should not be shared between fluid solvers running concurrently.
+type ConjugateGradient struct {
+	// Tolerance stops the iteration once the residual drops below it.
+	Tolerance float64
+	// MaxIterations limits the number of iterations. Zero means the number of grid cells.
+	MaxIterations int
+
+	precon, r, z, s, q cell
+}
+
+const (
+	// micTuning is the modification factor of the incomplete Cholesky preconditioner.
+	micTuning = 0.97
+	// micSafety falls back to the incomplete Cholesky diagonal when the modified one gets too small.
+	micSafety = 0.25
+)
+
+// SetPressureSolver replaces the pressure solver of the projection step.
+// A nil value restores the default Gauss-Seidel solver.
+func (fs *Solver) SetPressureSolver(ps PressureSolver) {
+	if ps == nil {
+		ps = &GaussSeidel{}
+	}
+	fs.pressure = ps
+}
+
+// PressureStats returns the iteration count and the final residual of the last pressure solve.
+func (fs *Solver) PressureStats() (iterations int, residual float64) {
+	return fs.pressureIterations, fs.pressureResidual
+}
+
+// Solve implements the PressureSolver interface.
+func (gs *GaussSeidel) Solve(fs *Solver, p, div []float64) (int, float64) {
+	g := fs.grid()
+	maxIter := gs.MaxIterations
+	if maxIter <= 0 {
+		maxIter = fs.config.Iterations
+	}
+
+	var (
+		it       int
+		residual float64
+	)
+	for it < maxIter {
+		g.relax(p, div)
+		g.boundary(p)
+		it++
+
+		if gs.Tolerance > 0 {
+			if residual = g.residual(p, div, nil); residual <= gs.Tolerance {
+				return it, residual
+			}
+		}
+	}
+	if gs.Tolerance <= 0 {
+		residual = g.residual(p, div, nil)
+	}
+	return it, residual
+}
+
+// Solve implements the PressureSolver interface.
+func (cg *ConjugateGradient) Solve(fs *Solver, p, div []float64) (int, float64) {
+	g := fs.grid()
+	maxIter := cg.MaxIterations
+	if maxIter <= 0 {
+		maxIter = g.nx * g.ny
+	}
+	if len(cg.r) != g.size() {
+		cg.precon = make(cell, g.size())
+		cg.r = make(cell, g.size())
+		cg.z = make(cell, g.size())
+		cg.s = make(cell, g.size())
+		cg.q = make(cell, g.size())
+	}
+	cg.factorize(g)
+
+	g.boundary(p)
+	residual := g.residual(p, div, cg.r)
+	if residual <= cg.Tolerance {
+		return 0, residual
+	}
+
+	cg.applyPrecon(g, cg.r, cg.z)
+	copy(cg.s, cg.z)
+	sigma := g.dot(cg.z, cg.r)
+
+	for it := 1; it <= maxIter; it++ {
+		g.boundary(cg.s)
+		g.apply(cg.s, cg.q)
+
+		sq := g.dot(cg.s, cg.q)
+		if sq == 0 {
+			g.boundary(p)
+			return it, residual
+		}
+		alpha := sigma / sq
+
+		residual = 0
+		for j := 1; j <= g.ny; j++ {
+			for i := 1; i <= g.nx; i++ {
+				k := g.idx(i, j)
+				p[k] += alpha * cg.s[k]
+				cg.r[k] -= alpha * cg.q[k]
+				residual = math.Max(residual, math.Abs(cg.r[k]))
+			}
+		}
+		if residual <= cg.Tolerance {
+			g.boundary(p)
+			return it, residual
+		}
+
+		cg.applyPrecon(g, cg.r, cg.z)
+		sigmaNew := g.dot(cg.z, cg.r)
+		beta := sigmaNew / sigma
+		for j := 1; j <= g.ny; j++ {
+			for i := 1; i <= g.nx; i++ {
+				k := g.idx(i, j)
+				cg.s[k] = cg.z[k] + beta*cg.s[k]
+			}
+		}
+		sigma = sigmaNew
+	}
+	g.boundary(p)
+
+	return maxIter, residual
+}
+
+// factorize computes the diagonal of the MIC(0) preconditioner.
+func (cg *ConjugateGradient) factorize(g grid) {
+	for j := 1; j <= g.ny; j++ {
+		for i := 1; i <= g.nx; i++ {
+			diag := g.diag(i, j)
+			if diag == 0 || !g.isFluid(i, j) {
+				// The solid and the isolated cells are not coupled with their neighbours.
+				cg.precon[g.idx(i, j)] = 0
+				continue
+			}
+			e := diag
+			if i > 1 {
+				pi := cg.precon[g.idx(i-1, j)]
+				e -= pi*pi + micTuning*g.plusJ(i-1, j)*pi*pi
+			}
+			if j > 1 {
+				pj := cg.precon[g.idx(i, j-1)]
+				e -= pj*pj + micTuning*g.plusI(i, j-1)*pj*pj
+			}
+			if e < micSafety*diag {
+				e = diag
+			}
+			cg.precon[g.idx(i, j)] = 1 / math.Sqrt(e)
+		}
+	}
+}
+
+// applyPrecon solves L*L^T*z = r, where L is the incomplete Cholesky factor.
+func (cg *ConjugateGradient) applyPrecon(g grid, r, z cell) {
+	q := cg.q
+	for j := 1; j <= g.ny; j++ {
+		for i := 1; i <= g.nx; i++ {
+			t := r[g.idx(i, j)]
+			if i > 1 {
+				t += cg.precon[g.idx(i-1, j)] * q[g.idx(i-1, j)]
+			}
+			if j > 1 {
+				t += cg.precon[g.idx(i, j-1)] * q[g.idx(i, j-1)]
+			}
+			q[g.idx(i, j)] = t * cg.precon[g.idx(i, j)]
+		}
+	}
+	for j := g.ny; j >= 1; j-- {
+		for i := g.nx; i >= 1; i-- {
+			t := q[g.idx(i, j)]
+			if i < g.nx {
+				t += cg.precon[g.idx(i, j)] * z[g.idx(i+1, j)]
+			}
+			if j < g.ny {
+				t += cg.precon[g.idx(i, j)] * z[g.idx(i, j+1)]
+			}
+			z[g.idx(i, j)] = t * cg.precon[g.idx(i, j)]
+		}
+	}
+}
+
+// grid describes the cell layout of a field, including the boundary cells.
+// The solid cells of the optional obstacle mask are left out of the Poisson equation.
+type grid struct {
+	nx, ny  int
+	workers int
+	solid   []Obstacle
+	edges   Edges
+}
+
+// grid returns the layout of the solver fields.
+func (fs *Solver) grid() grid {
+	return grid{nx: fs.nx, ny: fs.ny, workers: fs.config.Workers, solid: fs.obstacles, edges: fs.config.Edges}
+}
+
+// idx returns the cell's index (position).
+func (g grid) idx(i, j int) int {
+	return i + (g.nx+2)*j
+}
+
+// size returns the number of cells, including the boundary.
+func (g grid) size() int {
+	return (g.nx + 2) * (g.ny + 2)
+}
+
+// isFluid checks if cell (i, j) is a fluid cell inside the grid.
+func (g grid) isFluid(i, j int) bool {
+	if i < 1 || i > g.nx || j < 1 || j > g.ny {
+		return false
+	}
+	return g.solid == nil || g.solid[g.idx(i, j)] == ObstacleNone
+}
+
+// diag returns the diagonal of the Poisson matrix, which is the number of fluid cells
+// next to cell (i, j) and the contribution of the boundary cells along the edges.
+func (g grid) diag(i, j int) float64 {
+	var n float64
+	for _, nb := range [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+		ni, nj := i+nb[0], j+nb[1]
+		switch {
+		case ni < 1:
+			n += g.edges.Left.coupling()
+		case ni > g.nx:
+			n += g.edges.Right.coupling()
+		case nj < 1:
+			n += g.edges.Top.coupling()
+		case nj > g.ny:
+			n += g.edges.Bottom.coupling()
+		case g.isFluid(ni, nj):
+			n++
+		}
+	}
+	return n
+}
+
+// plusI returns 1 if cell (i, j) is coupled with its fluid neighbour at (i+1, j) through the Poisson matrix.
+func (g grid) plusI(i, j int) float64 {
+	if g.isFluid(i, j) && g.isFluid(i+1, j) {
+		return 1
+	}
+	return 0
+}
+
+// plusJ returns 1 if cell (i, j) is coupled with its fluid neighbour at (i, j+1).
+func (g grid) plusJ(i, j int) float64 {
+	if g.isFluid(i, j) && g.isFluid(i, j+1) {
+		return 1
+	}
+	return 0
+}
+
+// coupled returns the sum and the number of the neighbours coupled with the cell at index {k}.
+// The solid neighbours are left out, which means zero pressure gradient across their walls.
+func (g grid) coupled(x cell, k int) (sum, n float64) {
+	stride := g.nx + 2
+	for _, nk := range [4]int{k - 1, k + 1, k - stride, k + stride} {
+		if g.solid[nk] == ObstacleNone {
+			sum += x[nk]
+			n++
+		}
+	}
+	return sum, n
+}
+
+// boundary sets the pressure boundary cells. The pressure gradient is zero across
+// the walls, the pressure is zero along the outflow edges and wraps around the periodic ones.
+func (g grid) boundary(x cell) {
+	e := g.edges
+	for j := 1; j <= g.ny; j++ {
+		x[g.idx(0, j)] = e.Left.ghost(boundaryPressure, BoundaryLeftRight, x[g.idx(1, j)], x[g.idx(g.nx, j)])
+		x[g.idx(g.nx+1, j)] = e.Right.ghost(boundaryPressure, BoundaryLeftRight, x[g.idx(g.nx, j)], x[g.idx(1, j)])
+	}
+	for i := 1; i <= g.nx; i++ {
+		x[g.idx(i, 0)] = e.Top.ghost(boundaryPressure, BoundaryTopBottom, x[g.idx(i, 1)], x[g.idx(i, g.ny)])
+		x[g.idx(i, g.ny+1)] = e.Bottom.ghost(boundaryPressure, BoundaryTopBottom, x[g.idx(i, g.ny)], x[g.idx(i, 1)])
+	}
+}
+
+// relax runs a red-black Gauss-Seidel sweep of the Poisson equation.
+func (g grid) relax(x, b cell) {
+	for color := 0; color < 2; color++ {
+		rows(g.ny, g.workers, func(j0, j1 int) {
+			for j := j0; j <= j1; j++ {
+				for i := 2 - (j+color)%2; i <= g.nx; i += 2 {
+					k := g.idx(i, j)
+					if g.solid == nil {
+						x[k] = (b[k] + x[g.idx(i-1, j)] + x[g.idx(i+1, j)] + x[g.idx(i, j-1)] + x[g.idx(i, j+1)]) * 0.25
+					} else if g.solid[k] == ObstacleNone {
+						if sum, n := g.coupled(x, k); n > 0 {
+							x[k] = (b[k] + sum) / n
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+// apply computes the Poisson matrix product out = A*x. The boundary of {x} must be up to date.
+func (g grid) apply(x, out cell) {
+	rows(g.ny, g.workers, func(j0, j1 int) {
+		for j := j0; j <= j1; j++ {
+			for i := 1; i <= g.nx; i++ {
+				out[g.idx(i, j)] = g.product(x, i, j)
+			}
+		}
+	})
+}
+
+// residual returns the largest absolute residual of the Poisson equation and stores
+// the residuals into {r}, unless it's nil. The boundary of {x} must be up to date.
+func (g grid) residual(x, b, r cell) float64 {
+	var max float64
+	for j := 1; j <= g.ny; j++ {
+		for i := 1; i <= g.nx; i++ {
+			k := g.idx(i, j)
+			res := b[k] - g.product(x, i, j)
+			if g.solid != nil && g.solid[k] != ObstacleNone {
+				res = 0
+			}
+			if r != nil {
+				r[k] = res
+			}
+			if res = math.Abs(res); res > max {
+				max = res
+			}
+		}
+	}
+	return max
+}
+
+// product returns the row (i, j) of the Poisson matrix multiplied with {x}.
+func (g grid) product(x cell, i, j int) float64 {
+	k := g.idx(i, j)
+	if g.solid == nil {
+		return 4*x[k] - x[g.idx(i-1, j)] - x[g.idx(i+1, j)] - x[g.idx(i, j-1)] - x[g.idx(i, j+1)]
+	}
+	if g.solid[k] != ObstacleNone {
+		return 0
+	}
+	sum, n := g.coupled(x, k)
+	return n*x[k] - sum
+}
+
+// dot returns the dot product of the fluid cells.
+func (g grid) dot(a, b cell) float64 {
+	var sum float64
+	for j := 1; j <= g.ny; j++ {
+		for i := 1; i <= g.nx; i++ {
+			sum += a[g.idx(i, j)] * b[g.idx(i, j)]
+		}
+	}
+	return sum
+}
+
+// removeMean subtracts the average of the fluid cells, which makes the
+// right hand side of the Poisson equation compatible with the closed walls.
+func (g grid) removeMean(x cell) {
+	var mean, n float64
+	for j := 1; j <= g.ny; j++ {
+		for i := 1; i <= g.nx; i++ {
+			if g.isFluid(i, j) {
+				mean += x[g.idx(i, j)]
+				n++
+			}
+		}
+	}
+	if n == 0 {
+		return
+	}
+	mean /= n
+	for j := 1; j <= g.ny; j++ {
+		for i := 1; i <= g.nx; i++ {
+			if g.isFluid(i, j) {
+				x[g.idx(i, j)] -= mean
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package fluid
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestPressureSolvers(t *testing.T) {
+	for _, size := range [][2]int{{64, 64}, {72, 36}, {33, 17}} {
+		solvers := []PressureSolver{
+			&GaussSeidel{Tolerance: 1e-6, MaxIterations: 20000},
+			&ConjugateGradient{Tolerance: 1e-6},
+			&Multigrid{Tolerance: 1e-6, MaxIterations: 50},
+		}
+		for _, ps := range solvers {
+			fs := NewSolverWH(size[0], size[1])
+			g := fs.grid()
+			p, div := make(cell, g.size()), make(cell, g.size())
+			for j := 1; j <= g.ny; j++ {
+				for i := 1; i <= g.nx; i++ {
+					div[g.idx(i, j)] = float64((i*7+j*3)%11) - 5
+				}
+			}
+			g.removeMean(div)
+
+			it, residual := ps.Solve(fs, p, div)
+			if residual > 1e-6 {
+				t.Errorf("%dx%d %T: residual %g after %d iterations", size[0], size[1], ps, residual, it)
+			}
+			// The conjugate gradient residual is updated recursively, so allow for rounding errors.
+			if check := g.residual(p, div, nil); math.Abs(check-residual) > 1e-9 {
+				t.Errorf("%dx%d %T: reported residual %g, got %g", size[0], size[1], ps, residual, check)
+			}
+		}
+	}
+}
+
+func BenchmarkPressure256(b *testing.B) {
+	solvers := []PressureSolver{
+		&GaussSeidel{Tolerance: 1e-4, MaxIterations: 100000},
+		&ConjugateGradient{Tolerance: 1e-4},
+		&Multigrid{Tolerance: 1e-4, MaxIterations: 100},
+	}
+	for _, ps := range solvers {
+		b.Run(fmt.Sprintf("%T", ps), func(b *testing.B) {
+			fs := newBenchSolver(b, 256, 1)
+			fs.SetPressureSolver(ps)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				fs.VelocityStep()
+			}
+		})
+	}
+}
+
+func TestProjectRemovesDivergence(t *testing.T) {
+	solvers := []PressureSolver{
+		&GaussSeidel{Tolerance: 1e-8, MaxIterations: 20000},
+		&ConjugateGradient{Tolerance: 1e-8},
+		&Multigrid{Tolerance: 1e-8, MaxIterations: 50},
+	}
+	for _, ps := range solvers {
+		fs := NewSolverWH(48, 32)
+		fs.SetPressureSolver(ps)
+		// A smooth source in the middle of the closed grid.
+		for j := 1; j <= fs.ny; j++ {
+			for i := 1; i <= fs.nx; i++ {
+				x, y := (float64(i)-0.5)/float64(fs.nx), (float64(j)-0.5)/float64(fs.ny)
+				fs.u[fs.idx(i, j)] = math.Sin(2*math.Pi*x) * math.Sin(math.Pi*y)
+				fs.v[fs.idx(i, j)] = math.Sin(math.Pi*x) * math.Sin(2*math.Pi*y)
+			}
+		}
+		fs.setBoundary(BoundaryLeftRight, fs.u)
+		fs.setBoundary(BoundaryTopBottom, fs.v)
+
+		before := maxDivergence(fs)
+		fs.project(fs.u, fs.v, fs.uOld, fs.vOld)
+		if after := maxDivergence(fs); after > before/20 {
+			t.Errorf("%T: the divergence went from %v to %v", ps, before, after)
+		}
+	}
+}
+
+// maxDivergence returns the highest absolute divergence of the velocity field of {fs}.
+func maxDivergence(fs *Solver) float64 {
+	var max float64
+	for j := 1; j <= fs.ny; j++ {
+		for i := 1; i <= fs.nx; i++ {
+			d := 0.5 * (fs.u[fs.idx(i+1, j)] - fs.u[fs.idx(i-1, j)] + fs.v[fs.idx(i, j+1)] - fs.v[fs.idx(i, j-1)])
+			max = math.Max(max, math.Abs(d))
+		}
+	}
+	return max
+}
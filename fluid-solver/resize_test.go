@@ -0,0 +1,85 @@
+package fluid
+
+import (
+	"math"
+	"testing"
+)
+
+func TestResizeResamples(t *testing.T) {
+	fs := NewSolverWH(20, 10)
+	// A density ramp along the x axis, which the bilinear resampling keeps linear.
+	for j := 0; j <= 11; j++ {
+		for i := 0; i <= 21; i++ {
+			fs.d[fs.idx(i, j)] = (float64(i) - 0.5) / 20
+			fs.t[fs.idx(i, j)] = 3
+		}
+	}
+	fs.SetObstacle(15, 5, ObstacleNoSlip)
+	e := &Emitter{X: 10.5, Y: 5.5}
+	fs.AddEmitter(e)
+
+	if err := fs.Resize(40, 30); err != nil {
+		t.Fatal(err)
+	}
+	if nx, ny := fs.Size(); nx != 40 || ny != 30 {
+		t.Fatalf("size is %dx%d, want 40x30", nx, ny)
+	}
+	for j := 1; j <= 30; j++ {
+		for i := 1; i <= 40; i++ {
+			k := fs.idx(i, j)
+			if fs.isSolid(k) {
+				continue
+			}
+			if want := (float64(i) - 0.5) / 40; math.Abs(fs.d[k]-want) > 1e-12 {
+				t.Fatalf("density at (%d, %d) is %v, want %v", i, j, fs.d[k], want)
+			}
+			if fs.t[k] != 3 {
+				t.Fatalf("temperature at (%d, %d) is %v, want 3", i, j, fs.t[k])
+			}
+		}
+	}
+	// The obstacle cell covers 2x3 cells of the new grid.
+	var solid int
+	for _, o := range fs.obstacles {
+		if o != ObstacleNone {
+			solid++
+		}
+	}
+	if solid != 6 || fs.ObstacleAt(30, 14) != ObstacleNoSlip {
+		t.Errorf("the obstacle covers %d cells, want 6 around (30, 14)", solid)
+	}
+	if e.X != 20.5 || e.Y != 15.5 {
+		t.Errorf("the emitter moved to (%v, %v), want (20.5, 15.5)", e.X, e.Y)
+	}
+}
+
+func TestResizeKeepsRunning(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Advection = AdvectionBFECC
+	cfg.DyeChannels = 2
+
+	for _, ps := range []PressureSolver{&GaussSeidel{}, &ConjugateGradient{}, &Multigrid{}} {
+		fs, err := NewSolverConfig(32, 16, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs.SetPressureSolver(ps)
+		for _, size := range [][2]int{{32, 16}, {48, 20}, {12, 30}} {
+			if err := fs.Resize(size[0], size[1]); err != nil {
+				t.Fatal(err)
+			}
+			for step := 0; step < 5; step++ {
+				fs.SplatDensity(0.5, 0.7, 0.05, 10)
+				fs.SplatVelocity(0.5, 0.7, 0.05, 0, -0.05)
+				fs.Step(cfg.Dt)
+			}
+			if err := fs.CheckFinite(); err != nil {
+				t.Fatalf("%T at %dx%d: %v", ps, size[0], size[1], err)
+			}
+		}
+	}
+
+	if err := NewSolver(8).Resize(0, 8); err == nil {
+		t.Error("expected an error for an empty grid")
+	}
+}
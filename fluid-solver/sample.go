@@ -0,0 +1,80 @@
+package fluid
+
+// SampleVelocity returns the bilinearly interpolated velocity at {x, y}. The position is given
+// in normalized coordinates, like the ones of SplatDensity: {0, 0} is the top left corner of
+// the grid and {1, 1} the bottom right one, while the center of cell {i, j} is at
+// {(i-0.5)/N, (j-0.5)/M}. The positions outside of the grid are clamped to its edges,
+// or wrapped around the periodic edges, and the NaN coordinates are clamped to the top left edges.
+func (fs *Solver) SampleVelocity(x, y float64) (u, v float64) {
+	px, py := fs.gridPos(x, y)
+	return fs.interpolate(fs.u, px, py), fs.interpolate(fs.v, px, py)
+}
+
+// SampleDensity returns the bilinearly interpolated density at {x, y}.
+// The coordinates are the same as the ones of SampleVelocity.
+func (fs *Solver) SampleDensity(x, y float64) float64 {
+	px, py := fs.gridPos(x, y)
+	return fs.interpolate(fs.d, px, py)
+}
+
+// Trace moves the point {x, y} along the velocity field over {dt} time, using the second order
+// Runge-Kutta (midpoint) method. The coordinates are the same as the ones of SampleVelocity,
+// but the returned position is neither clamped nor wrapped around the grid.
+func (fs *Solver) Trace(x, y, dt float64) (float64, float64) {
+	vx, vy := fs.FlowVelocity(x, y)
+	mx, my := x+0.5*dt*vx, y+0.5*dt*vy
+
+	vx, vy = fs.FlowVelocity(mx, my)
+	return x + dt*vx, y + dt*vy
+}
+
+// FlowVelocity returns the velocity at {x, y} in normalized coordinates per unit of time.
+// The velocity field is relative to the longer side of the grid, so it's rescaled along the shorter one.
+func (fs *Solver) FlowVelocity(x, y float64) (vx, vy float64) {
+	u, v := fs.SampleVelocity(x, y)
+	return u * fs.scale() / float64(fs.nx), v * fs.scale() / float64(fs.ny)
+}
+
+// Deposit adds the force {fx, fy}, given in normalized coordinates like the velocity of FlowVelocity,
+// and the density rate {density} to the sources of the fluid at {x, y}. They are spread bilinearly
+// over the fluid cells around the position, which share the whole amount, and injected into
+// the fluid on the next step.
+func (fs *Solver) Deposit(x, y, fx, fy, density float64) {
+	var total float64
+	fs.bilinear(x, y, func(k int, w float64) {
+		total += w
+	})
+	if total == 0 {
+		return
+	}
+
+	du, dv := fx*float64(fs.nx)/fs.scale(), fy*float64(fs.ny)/fs.scale()
+	fs.bilinear(x, y, func(k int, w float64) {
+		w /= total
+		fs.uOld[k] += w * du
+		fs.vOld[k] += w * dv
+		fs.dOld[k] += w * density
+	})
+}
+
+// bilinear calls {fn} with the index and the bilinear weight of the fluid cells around the normalized
+// position {x, y}. The boundary and the solid cells are skipped, so the weights may sum up to less than 1.
+func (fs *Solver) bilinear(x, y float64, fn func(k int, w float64)) {
+	px, py := fs.gridPos(x, y)
+	i0, j0 := int(px), int(py)
+	s1, t1 := px-float64(i0), py-float64(j0)
+
+	for _, c := range [4][3]float64{{0, 0, (1 - s1) * (1 - t1)}, {1, 0, s1 * (1 - t1)}, {0, 1, (1 - s1) * t1}, {1, 1, s1 * t1}} {
+		i, j := i0+int(c[0]), j0+int(c[1])
+		if c[2] == 0 || i < 1 || i > fs.nx || j < 1 || j > fs.ny || fs.isSolid(fs.idx(i, j)) {
+			continue
+		}
+		fn(fs.idx(i, j), c[2])
+	}
+}
+
+// gridPos converts the normalized position {x, y} into grid coordinates, where the center
+// of cell {i, j} is at {i, j}, and clamps it into the grid.
+func (fs *Solver) gridPos(x, y float64) (float64, float64) {
+	return fs.clampPos(x*float64(fs.nx)+0.5, y*float64(fs.ny)+0.5)
+}
@@ -0,0 +1,98 @@
+package fluid
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSampleDensity(t *testing.T) {
+	fs := NewSolverWH(40, 20)
+	for j := 0; j <= 21; j++ {
+		for i := 0; i <= 41; i++ {
+			fs.d[fs.idx(i, j)] = float64(i) + 10*float64(j)
+		}
+	}
+	// The cell centers are at {(i-0.5)/N, (j-0.5)/M}, and the samples in between are interpolated.
+	for _, c := range []struct{ x, y, want float64 }{
+		{9.5 / 40, 4.5 / 20, 10 + 50},
+		{9.75 / 40, 4.5 / 20, 10.25 + 50},
+		{13.2 / 40, 7.9 / 20, 13.7 + 84},
+	} {
+		if got := fs.SampleDensity(c.x, c.y); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("density at (%v, %v) is %v, want %v", c.x, c.y, got, c.want)
+		}
+	}
+	// The samples outside of the grid are clamped to its edges.
+	if got, want := fs.SampleDensity(-1, 0.5), fs.SampleDensity(0, 0.5); got != want {
+		t.Errorf("density left of the grid is %v, want %v", got, want)
+	}
+}
+
+func TestSampleNonFinite(t *testing.T) {
+	periodic := Edge{Type: EdgePeriodic}
+	cfg := DefaultConfig()
+	cfg.Edges = Edges{Left: periodic, Right: periodic, Top: periodic, Bottom: periodic}
+	wrapped, err := NewSolverConfig(16, 16, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fs := range []*Solver{NewSolver(16), wrapped} {
+		for k := range fs.u {
+			fs.u[k], fs.v[k], fs.d[k] = 0.01, -0.01, 1
+		}
+		for _, c := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+			if u, v := fs.SampleVelocity(c, c); u != 0.01 || v != -0.01 {
+				t.Errorf("velocity at %v is (%v, %v), want (0.01, -0.01)", c, u, v)
+			}
+			if d := fs.SampleDensity(0.5, c); d != 1 {
+				t.Errorf("density at %v is %v, want 1", c, d)
+			}
+			// The traced position isn't finite, but tracing it doesn't panic.
+			fs.Trace(0.5, 0.5, c)
+			fs.Trace(c, c, 1)
+		}
+	}
+}
+
+func TestTraceUniform(t *testing.T) {
+	fs := NewSolverWH(40, 20)
+	for k := range fs.u {
+		fs.u[k], fs.v[k] = 0.01, 0.01
+	}
+	// The velocity is relative to the longer side, so the point moves 0.4 cells along both axes.
+	x, y := fs.Trace(0.5, 0.5, 1)
+	if math.Abs(x-(0.5+0.4/40)) > 1e-12 || math.Abs(y-(0.5+0.4/20)) > 1e-12 {
+		t.Errorf("the point moved to (%v, %v), want (%v, %v)", x, y, 0.5+0.4/40, 0.5+0.4/20)
+	}
+}
+
+func TestTraceRotation(t *testing.T) {
+	const (
+		n     = 64
+		omega = 0.01
+		steps = 200
+	)
+	fs := NewSolver(n)
+	for j := 0; j <= n+1; j++ {
+		for i := 0; i <= n+1; i++ {
+			x, y := (float64(i)-0.5)/n, (float64(j)-0.5)/n
+			fs.u[fs.idx(i, j)] = -omega * (y - 0.5)
+			fs.v[fs.idx(i, j)] = omega * (x - 0.5)
+		}
+	}
+
+	// A point circling around the center with the solid body rotation keeps its radius,
+	// while the forward Euler integration would spiral outwards by about 10%.
+	x, y := 0.75, 0.5
+	dt := 2 * math.Pi / omega / steps
+	for s := 0; s < steps; s++ {
+		x, y = fs.Trace(x, y, dt)
+	}
+	if r := math.Hypot(x-0.5, y-0.5); math.Abs(r-0.25) > 0.25*1e-3 {
+		t.Errorf("the radius changed to %v after a revolution, want 0.25", r)
+	}
+	if math.Abs(x-0.75) > 1e-2 || math.Abs(y-0.5) > 1e-2 {
+		t.Errorf("the point ended at (%v, %v) after a revolution, want (0.75, 0.5)", x, y)
+	}
+}
@@ -0,0 +1,264 @@
+package fluid
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrInvalidSnapshot is returned when the snapshot data can't be restored.
+var ErrInvalidSnapshot = errors.New("fluid: invalid snapshot")
+
+const (
+	snapshotMagic   = "FLUD"
+	snapshotVersion = 3
+
+	// maxSnapshotCells limits the grid size of the restored snapshots.
+	maxSnapshotCells = 1 << 24
+)
+
+// snapshotHeader is the fixed size header of the snapshot format.
+type snapshotHeader struct {
+	Magic   [4]byte
+	Version uint16
+	Nx, Ny  uint32
+}
+
+// snapshotConfig is the fixed size encoding of the solver configuration.
+type snapshotConfig struct {
+	Dt, Diffusion, Viscosity float64
+	Iterations               int64
+	Advection                int64
+	Workers                  int64
+
+	Vorticity         uint8
+	VorticityStrength float64
+
+	Buoyancy                     uint8
+	BuoyancyWeight, BuoyancyLift float64
+
+	AmbientTemperature, TemperatureDiffusion, Cooling float64
+
+	DyeChannels int64
+	Edges       [4]snapshotEdge
+}
+
+// snapshotStepping is the fixed size encoding of the substepping configuration, added in version 2.
+type snapshotStepping struct {
+	CFL         float64
+	MaxSubsteps int64
+}
+
+// snapshotForces is the fixed size encoding of the gravity, added in version 3.
+// It's followed by the constant forces of the cells, if there are any.
+type snapshotForces struct {
+	GravityX, GravityY float64
+	HasForces          uint8
+}
+
+// snapshotEdge is the fixed size encoding of an edge condition.
+type snapshotEdge struct {
+	Type int64
+	U, V float64
+}
+
+// Snapshot writes the grid size, the configuration and all the fields of the solver to {w}.
+// The data is encoded in a versioned little-endian binary format, which can be read by Restore.
+// The pressure solver and the force providers are not part of the snapshot.
+func (fs *Solver) Snapshot(w io.Writer) error {
+	hdr := snapshotHeader{Version: snapshotVersion, Nx: uint32(fs.nx), Ny: uint32(fs.ny)}
+	copy(hdr.Magic[:], snapshotMagic)
+
+	if err := binary.Write(w, binary.LittleEndian, hdr); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, encodeConfig(fs.config)); err != nil {
+		return err
+	}
+	stepping := snapshotStepping{CFL: fs.config.CFL, MaxSubsteps: int64(fs.config.MaxSubsteps)}
+	if err := binary.Write(w, binary.LittleEndian, stepping); err != nil {
+		return err
+	}
+	forces := snapshotForces{GravityX: fs.config.GravityX, GravityY: fs.config.GravityY}
+	if fs.forceU != nil {
+		forces.HasForces = 1
+	}
+	if err := binary.Write(w, binary.LittleEndian, forces); err != nil {
+		return err
+	}
+	for _, c := range fs.snapshotFields() {
+		if err := binary.Write(w, binary.LittleEndian, c); err != nil {
+			return err
+		}
+	}
+	if fs.forceU != nil {
+		for _, c := range []cell{fs.forceU, fs.forceV} {
+			if err := binary.Write(w, binary.LittleEndian, c); err != nil {
+				return err
+			}
+		}
+	}
+
+	hasObstacles := uint8(0)
+	if fs.obstacles != nil {
+		hasObstacles = 1
+	}
+	if err := binary.Write(w, binary.LittleEndian, hasObstacles); err != nil {
+		return err
+	}
+	if fs.obstacles != nil {
+		return binary.Write(w, binary.LittleEndian, fs.obstacles)
+	}
+	return nil
+}
+
+// Restore creates a new solver from the snapshot data written by Solver.Snapshot.
+// The snapshots of the earlier format versions are restored with the default values
+// of the configuration fields they don't contain.
+func Restore(r io.Reader) (*Solver, error) {
+	var hdr snapshotHeader
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	if string(hdr.Magic[:]) != snapshotMagic {
+		return nil, fmt.Errorf("%w: not a fluid snapshot", ErrInvalidSnapshot)
+	}
+	if hdr.Version < 1 || hdr.Version > snapshotVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidSnapshot, hdr.Version)
+	}
+	if hdr.Nx == 0 || hdr.Ny == 0 || (uint64(hdr.Nx)+2)*(uint64(hdr.Ny)+2) > maxSnapshotCells {
+		return nil, fmt.Errorf("%w: grid size %dx%d", ErrInvalidSnapshot, hdr.Nx, hdr.Ny)
+	}
+
+	var sc snapshotConfig
+	if err := binary.Read(r, binary.LittleEndian, &sc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	if sc.DyeChannels < 0 || sc.DyeChannels > 64 {
+		return nil, fmt.Errorf("%w: %d dye channels", ErrInvalidSnapshot, sc.DyeChannels)
+	}
+	cfg := decodeConfig(sc)
+	if hdr.Version >= 2 {
+		var stepping snapshotStepping
+		if err := binary.Read(r, binary.LittleEndian, &stepping); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+		}
+		cfg.CFL, cfg.MaxSubsteps = stepping.CFL, int(stepping.MaxSubsteps)
+	}
+	var forces snapshotForces
+	if hdr.Version >= 3 {
+		if err := binary.Read(r, binary.LittleEndian, &forces); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+		}
+		cfg.GravityX, cfg.GravityY = forces.GravityX, forces.GravityY
+	}
+	fs, err := NewSolverConfig(int(hdr.Nx), int(hdr.Ny), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+
+	for _, c := range fs.snapshotFields() {
+		if err := binary.Read(r, binary.LittleEndian, c); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+		}
+	}
+	if forces.HasForces != 0 {
+		fs.forceU = make(cell, fs.numOfCells)
+		fs.forceV = make(cell, fs.numOfCells)
+		for _, c := range []cell{fs.forceU, fs.forceV} {
+			if err := binary.Read(r, binary.LittleEndian, c); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+			}
+		}
+	}
+
+	var hasObstacles uint8
+	if err := binary.Read(r, binary.LittleEndian, &hasObstacles); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	if hasObstacles != 0 {
+		fs.obstacles = make([]Obstacle, fs.numOfCells)
+		if err := binary.Read(r, binary.LittleEndian, fs.obstacles); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+		}
+		for _, o := range fs.obstacles {
+			if o > ObstacleFreeSlip {
+				return nil, fmt.Errorf("%w: unknown obstacle type %d", ErrInvalidSnapshot, o)
+			}
+		}
+	}
+	return fs, nil
+}
+
+// snapshotFields returns the fields stored in the snapshots, in the order of the format.
+func (fs *Solver) snapshotFields() []cell {
+	fields := []cell{fs.u, fs.v, fs.d, fs.t, fs.uOld, fs.vOld, fs.dOld, fs.tOld}
+	for _, dy := range fs.dyes {
+		fields = append(fields, dy.d, dy.dOld)
+	}
+	return fields
+}
+
+// encodeConfig converts the configuration into its fixed size encoding.
+func encodeConfig(c SolverConfig) snapshotConfig {
+	sc := snapshotConfig{
+		Dt:                   c.Dt,
+		Diffusion:            c.Diffusion,
+		Viscosity:            c.Viscosity,
+		Iterations:           int64(c.Iterations),
+		Advection:            int64(c.Advection),
+		Workers:              int64(c.Workers),
+		VorticityStrength:    c.VorticityStrength,
+		BuoyancyWeight:       c.BuoyancyWeight,
+		BuoyancyLift:         c.BuoyancyLift,
+		AmbientTemperature:   c.AmbientTemperature,
+		TemperatureDiffusion: c.TemperatureDiffusion,
+		Cooling:              c.Cooling,
+		DyeChannels:          int64(c.DyeChannels),
+	}
+	if c.Vorticity {
+		sc.Vorticity = 1
+	}
+	if c.Buoyancy {
+		sc.Buoyancy = 1
+	}
+	for i, e := range []Edge{c.Edges.Left, c.Edges.Right, c.Edges.Top, c.Edges.Bottom} {
+		sc.Edges[i] = snapshotEdge{Type: int64(e.Type), U: e.U, V: e.V}
+	}
+	return sc
+}
+
+// decodeConfig converts the fixed size encoding back into the configuration.
+// The fields missing from the encoding get their default values.
+func decodeConfig(sc snapshotConfig) SolverConfig {
+	edge := func(e snapshotEdge) Edge {
+		return Edge{Type: EdgeType(e.Type), U: e.U, V: e.V}
+	}
+	def := DefaultConfig()
+	return SolverConfig{
+		Dt:                   sc.Dt,
+		CFL:                  def.CFL,
+		MaxSubsteps:          def.MaxSubsteps,
+		Diffusion:            sc.Diffusion,
+		Viscosity:            sc.Viscosity,
+		Iterations:           int(sc.Iterations),
+		Advection:            AdvectionScheme(sc.Advection),
+		Workers:              int(sc.Workers),
+		Vorticity:            sc.Vorticity != 0,
+		VorticityStrength:    sc.VorticityStrength,
+		Buoyancy:             sc.Buoyancy != 0,
+		BuoyancyWeight:       sc.BuoyancyWeight,
+		BuoyancyLift:         sc.BuoyancyLift,
+		AmbientTemperature:   sc.AmbientTemperature,
+		TemperatureDiffusion: sc.TemperatureDiffusion,
+		Cooling:              sc.Cooling,
+		DyeChannels:          int(sc.DyeChannels),
+		Edges: Edges{
+			Left:   edge(sc.Edges[0]),
+			Right:  edge(sc.Edges[1]),
+			Top:    edge(sc.Edges[2]),
+			Bottom: edge(sc.Edges[3]),
+		},
+	}
+}
@@ -0,0 +1,80 @@
+package fluid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DyeChannels = 3
+	cfg.Advection = AdvectionBFECC
+	cfg.Edges.Left = Edge{Type: EdgeInflow, U: 0.02}
+	cfg.Edges.Right = Edge{Type: EdgeOutflow}
+	cfg.GravityY = 0.001
+
+	fs, err := NewSolverConfig(24, 12, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.SetObstacle(10, 6, ObstacleFreeSlip)
+	fs.SetForce(15, 4, 0.01, -0.02)
+	for step := 0; step < 10; step++ {
+		fs.Set(FieldDOld, 5, 6, 50)
+		fs.AddHeat(5, 6, 20)
+		fs.AddDye(1, 5, 6, 30)
+		fs.VelocityStep()
+		fs.DensityStep()
+	}
+
+	var buf bytes.Buffer
+	if err := fs.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := Restore(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(restored.Config(), fs.Config()) {
+		t.Errorf("config differs: got %+v, want %+v", restored.Config(), fs.Config())
+	}
+	if !reflect.DeepEqual(restored.snapshotFields(), fs.snapshotFields()) {
+		t.Error("fields differ from the snapshot source")
+	}
+	if !reflect.DeepEqual(restored.obstacles, fs.obstacles) {
+		t.Error("obstacles differ from the snapshot source")
+	}
+	if !reflect.DeepEqual(restored.forceU, fs.forceU) || !reflect.DeepEqual(restored.forceV, fs.forceV) {
+		t.Error("forces differ from the snapshot source")
+	}
+}
+
+func TestRestoreInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewSolver(8).Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	corrupted := append([]byte("XXXX"), data[4:]...)
+	if _, err := Restore(bytes.NewReader(corrupted)); !errors.Is(err, ErrInvalidSnapshot) {
+		t.Errorf("corrupted magic: got %v, want ErrInvalidSnapshot", err)
+	}
+	if _, err := Restore(bytes.NewReader(data[:len(data)/2])); !errors.Is(err, ErrInvalidSnapshot) {
+		t.Errorf("truncated data: got %v, want ErrInvalidSnapshot", err)
+	}
+
+	// The grid size is right after the magic and the version. The size of the largest grid
+	// would overflow to a single cell when the boundary cells are added in 32 bits.
+	huge := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint32(huge[6:], math.MaxUint32)
+	binary.LittleEndian.PutUint32(huge[10:], math.MaxUint32)
+	if _, err := Restore(bytes.NewReader(huge)); !errors.Is(err, ErrInvalidSnapshot) {
+		t.Errorf("huge grid: got %v, want ErrInvalidSnapshot", err)
+	}
+}
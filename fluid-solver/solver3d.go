@@ -0,0 +1,558 @@
+package fluid
+
+import (
+	"fmt"
+	"math"
+)
+
+// boundaryFrontBack marks the velocity component along the z axis of the 3D solver.
+const boundaryFrontBack BoundaryType = boundaryPressure + 1
+
+// Solver3D is a fluid solver on a cubic grid of N x N x N cells. It carries the diffusion, advection,
+// pressure projection, vorticity confinement and buoyancy of the 2D solver over to three dimensions.
+// The grid is surrounded by closed walls and the y axis points down, like the rows of the 2D solver.
+type Solver3D struct {
+	n          int
+	numOfCells int
+	config     SolverConfig
+	dt         float64
+
+	u cell
+	v cell
+	w cell
+	d cell
+	t cell
+
+	uOld cell
+	vOld cell
+	wOld cell
+	dOld cell
+	tOld cell
+
+	curlX   cell
+	curlY   cell
+	curlZ   cell
+	curlMag cell
+}
+
+// NewSolver3D creates a 3D fluid solver with {n} fluid cells along each axis, using the default configuration.
+func NewSolver3D(n int) *Solver3D {
+	fs := &Solver3D{n: n, config: DefaultConfig()}
+	fs.dt = fs.config.Dt
+	fs.numOfCells = (n + 2) * (n + 2) * (n + 2)
+
+	for _, c := range []*cell{
+		&fs.u, &fs.v, &fs.w, &fs.d, &fs.t,
+		&fs.uOld, &fs.vOld, &fs.wOld, &fs.dOld, &fs.tOld,
+		&fs.curlX, &fs.curlY, &fs.curlZ, &fs.curlMag,
+	} {
+		*c = make(cell, fs.numOfCells)
+	}
+	return fs
+}
+
+// NewSolver3DConfig creates a 3D fluid solver with {n} fluid cells along each axis, using the parameters
+// defined in {cfg}. The advection scheme, the dye channels, the gravity and the edges of the configuration
+// are not used: the fields are advected with the semi-Lagrangian scheme between closed walls.
+func NewSolver3DConfig(n int, cfg SolverConfig) (*Solver3D, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("%w: grid size must be positive, got %d", ErrInvalidConfig, n)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	fs := NewSolver3D(n)
+	fs.config = cfg
+	fs.dt = cfg.Dt
+	fs.ResetTemperature()
+
+	return fs, nil
+}
+
+// Size returns the number of fluid cells (not including the boundary) along each axis.
+func (fs *Solver3D) Size() int {
+	return fs.n
+}
+
+// Config returns the current solver configuration.
+func (fs *Solver3D) Config() SolverConfig {
+	return fs.config
+}
+
+// SetConfig updates the solver configuration. The change is applied from the next simulation step.
+func (fs *Solver3D) SetConfig(cfg SolverConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	fs.config = cfg
+	return nil
+}
+
+// Index returns the index of cell {x, y, z} in the slices returned by Density, Velocity and Temperature.
+func (fs *Solver3D) Index(x, y, z int) int {
+	return fs.idx(x, y, z)
+}
+
+// Density returns a read-only view of the density field.
+// The view is only valid until the next simulation step.
+func (fs *Solver3D) Density() []float64 {
+	return fs.d
+}
+
+// Velocity returns read-only views of the velocity components.
+// The views are only valid until the next simulation step.
+func (fs *Solver3D) Velocity() (u, v, w []float64) {
+	return fs.u, fs.v, fs.w
+}
+
+// Temperature returns a read-only view of the temperature field.
+// The view is only valid until the next simulation step.
+func (fs *Solver3D) Temperature() []float64 {
+	return fs.t
+}
+
+// AddDensity adds {amount} of density to cell {x, y, z}. The density is injected on the next density step.
+func (fs *Solver3D) AddDensity(x, y, z int, amount float64) error {
+	if !fs.inBounds(x, y, z) {
+		return fmt.Errorf("%w: (%d, %d, %d)", ErrOutOfBounds, x, y, z)
+	}
+	fs.dOld[fs.idx(x, y, z)] += amount
+	return nil
+}
+
+// AddVelocity adds the force {du, dv, dw} to cell {x, y, z}. The force is applied on the next velocity step.
+func (fs *Solver3D) AddVelocity(x, y, z int, du, dv, dw float64) error {
+	if !fs.inBounds(x, y, z) {
+		return fmt.Errorf("%w: (%d, %d, %d)", ErrOutOfBounds, x, y, z)
+	}
+	k := fs.idx(x, y, z)
+	fs.uOld[k] += du
+	fs.vOld[k] += dv
+	fs.wOld[k] += dw
+	return nil
+}
+
+// AddHeat adds {amount} of heat to cell {x, y, z}, independently of the density.
+// The heat is injected on the next density step.
+func (fs *Solver3D) AddHeat(x, y, z int, amount float64) error {
+	if !fs.inBounds(x, y, z) {
+		return fmt.Errorf("%w: (%d, %d, %d)", ErrOutOfBounds, x, y, z)
+	}
+	fs.tOld[fs.idx(x, y, z)] += amount
+	return nil
+}
+
+// ResetTemperature sets the temperature of all the cells to the ambient temperature.
+func (fs *Solver3D) ResetTemperature() {
+	for i := range fs.t {
+		fs.t[i] = fs.config.AmbientTemperature
+	}
+}
+
+// DensitySlice returns the density of the z-slice {z}. The slice is laid out like the fields of
+// a 2D solver with an N x N grid, including the boundary cells.
+func (fs *Solver3D) DensitySlice(z int) ([]float64, error) {
+	if z < 0 || z > fs.n+1 {
+		return nil, fmt.Errorf("%w: slice %d", ErrOutOfBounds, z)
+	}
+	stride := (fs.n + 2) * (fs.n + 2)
+	slice := make([]float64, stride)
+	copy(slice, fs.d[z*stride:(z+1)*stride])
+
+	return slice, nil
+}
+
+// DensityProjection returns the maximum intensity projection of the density along the z axis.
+// The projection is laid out like the fields of a 2D solver with an N x N grid.
+func (fs *Solver3D) DensityProjection() []float64 {
+	stride := (fs.n + 2) * (fs.n + 2)
+	proj := make([]float64, stride)
+	for z := 1; z <= fs.n; z++ {
+		for k, d := range fs.d[z*stride : (z+1)*stride] {
+			proj[k] = math.Max(proj[k], d)
+		}
+	}
+	return proj
+}
+
+// Step advances the simulation by {dt}, splitting it into substeps like Solver.Step.
+// The sources are applied over the whole time step like in Solver.Step as well: the velocity
+// sources before the first substep, and the density and the heat sources after its velocity step.
+// It returns the number of the substeps run.
+func (fs *Solver3D) Step(dt float64) int {
+	if !isFinite(dt) || dt <= 0 {
+		return 0
+	}
+	fs.dt = dt
+	fs.flushSources([][2]cell{{fs.u, fs.uOld}, {fs.v, fs.vOld}, {fs.w, fs.wOld}})
+
+	n := int(math.Ceil(dt * fs.maxSpeed() * float64(fs.n) / fs.config.CFL))
+	if n < 1 {
+		n = 1
+	}
+	if n > fs.config.MaxSubsteps {
+		n = fs.config.MaxSubsteps
+	}
+	fs.dt = dt / float64(n)
+	for s := 0; s < n; s++ {
+		fs.velocityStep()
+		if s == 0 {
+			fs.dt = dt
+			fs.flushSources([][2]cell{{fs.d, fs.dOld}, {fs.t, fs.tOld}})
+			fs.dt = dt / float64(n)
+		}
+		fs.densityStep()
+	}
+	return n
+}
+
+// VelocityStep calculates the velocity step, using the time step of the configuration.
+func (fs *Solver3D) VelocityStep() {
+	fs.dt = fs.config.Dt
+	fs.velocityStep()
+}
+
+// DensityStep calculates the density and the temperature step, using the time step of the configuration.
+func (fs *Solver3D) DensityStep() {
+	fs.dt = fs.config.Dt
+	fs.densityStep()
+}
+
+// velocityStep calculates the velocity step over the current time step.
+func (fs *Solver3D) velocityStep() {
+	fs.addSource(fs.u, fs.uOld)
+	fs.addSource(fs.v, fs.vOld)
+	fs.addSource(fs.w, fs.wOld)
+
+	if fs.config.Vorticity {
+		fs.vorticityConfinement(fs.uOld, fs.vOld, fs.wOld)
+		fs.addSource(fs.u, fs.uOld)
+		fs.addSource(fs.v, fs.vOld)
+		fs.addSource(fs.w, fs.wOld)
+	}
+
+	if fs.config.Buoyancy {
+		fs.buoyancy(fs.vOld)
+		fs.addSource(fs.v, fs.vOld)
+	}
+
+	fs.u, fs.uOld = fs.uOld, fs.u
+	fs.diffuse(BoundaryLeftRight, fs.u, fs.uOld, fs.config.Viscosity)
+	fs.v, fs.vOld = fs.vOld, fs.v
+	fs.diffuse(BoundaryTopBottom, fs.v, fs.vOld, fs.config.Viscosity)
+	fs.w, fs.wOld = fs.wOld, fs.w
+	fs.diffuse(boundaryFrontBack, fs.w, fs.wOld, fs.config.Viscosity)
+
+	fs.project(fs.u, fs.v, fs.w, fs.uOld, fs.vOld)
+	fs.u, fs.uOld = fs.uOld, fs.u
+	fs.v, fs.vOld = fs.vOld, fs.v
+	fs.w, fs.wOld = fs.wOld, fs.w
+
+	fs.advect(BoundaryLeftRight, fs.u, fs.uOld, fs.uOld, fs.vOld, fs.wOld)
+	fs.advect(BoundaryTopBottom, fs.v, fs.vOld, fs.uOld, fs.vOld, fs.wOld)
+	fs.advect(boundaryFrontBack, fs.w, fs.wOld, fs.uOld, fs.vOld, fs.wOld)
+
+	fs.project(fs.u, fs.v, fs.w, fs.uOld, fs.vOld)
+
+	// reset for the next step
+	for i := 0; i < fs.numOfCells; i++ {
+		fs.uOld[i] = 0
+		fs.vOld[i] = 0
+		fs.wOld[i] = 0
+	}
+}
+
+// densityStep calculates the density and the temperature step over the current time step.
+func (fs *Solver3D) densityStep() {
+	fs.addSource(fs.d, fs.dOld)
+	fs.d, fs.dOld = fs.dOld, fs.d
+	fs.diffuse(BoundaryNone, fs.d, fs.dOld, fs.config.Diffusion)
+	fs.d, fs.dOld = fs.dOld, fs.d
+	fs.advect(BoundaryNone, fs.d, fs.dOld, fs.u, fs.v, fs.w)
+
+	fs.addSource(fs.t, fs.tOld)
+	fs.t, fs.tOld = fs.tOld, fs.t
+	fs.diffuse(BoundaryNone, fs.t, fs.tOld, fs.config.TemperatureDiffusion)
+	fs.t, fs.tOld = fs.tOld, fs.t
+	fs.advect(BoundaryNone, fs.t, fs.tOld, fs.u, fs.v, fs.w)
+
+	cooling := fs.config.Cooling * fs.dt
+	if cooling > 1 {
+		cooling = 1
+	}
+	// reset for the next step
+	for i := 0; i < fs.numOfCells; i++ {
+		fs.t[i] -= cooling * (fs.t[i] - fs.config.AmbientTemperature)
+		fs.dOld[i] = 0
+		fs.tOld[i] = 0
+	}
+}
+
+// addSource integrates the sources over the current time step.
+func (fs *Solver3D) addSource(x, s cell) {
+	for i := 0; i < fs.numOfCells; i++ {
+		x[i] += s[i] * fs.dt
+	}
+}
+
+// flushSources adds the sources to their fields over the current time step and clears them.
+func (fs *Solver3D) flushSources(sources [][2]cell) {
+	for _, src := range sources {
+		fs.addSource(src[0], src[1])
+		for i := range src[1] {
+			src[1][i] = 0
+		}
+	}
+}
+
+// vorticityConfinement calculates the force pushing the fluid around the vortex cores, which
+// is perpendicular to both the vorticity and the gradient of the vorticity magnitude.
+func (fs *Solver3D) vorticityConfinement(fx, fy, fz cell) {
+	n := fs.n
+	for k := 1; k <= n; k++ {
+		for j := 1; j <= n; j++ {
+			for i := 1; i <= n; i++ {
+				c := fs.idx(i, j, k)
+				wx := (fs.w[fs.idx(i, j+1, k)]-fs.w[fs.idx(i, j-1, k)])*0.5 - (fs.v[fs.idx(i, j, k+1)]-fs.v[fs.idx(i, j, k-1)])*0.5
+				wy := (fs.u[fs.idx(i, j, k+1)]-fs.u[fs.idx(i, j, k-1)])*0.5 - (fs.w[fs.idx(i+1, j, k)]-fs.w[fs.idx(i-1, j, k)])*0.5
+				wz := (fs.v[fs.idx(i+1, j, k)]-fs.v[fs.idx(i-1, j, k)])*0.5 - (fs.u[fs.idx(i, j+1, k)]-fs.u[fs.idx(i, j-1, k)])*0.5
+
+				fs.curlX[c], fs.curlY[c], fs.curlZ[c] = wx, wy, wz
+				fs.curlMag[c] = math.Sqrt(wx*wx + wy*wy + wz*wz)
+			}
+		}
+	}
+
+	for k := 1; k <= n; k++ {
+		for j := 1; j <= n; j++ {
+			for i := 1; i <= n; i++ {
+				c := fs.idx(i, j, k)
+				nx := (fs.curlMag[fs.idx(i+1, j, k)] - fs.curlMag[fs.idx(i-1, j, k)]) * 0.5
+				ny := (fs.curlMag[fs.idx(i, j+1, k)] - fs.curlMag[fs.idx(i, j-1, k)]) * 0.5
+				nz := (fs.curlMag[fs.idx(i, j, k+1)] - fs.curlMag[fs.idx(i, j, k-1)]) * 0.5
+
+				norm := math.Sqrt(nx*nx + ny*ny + nz*nz)
+				if norm == 0 {
+					// Avoid devide by zero
+					norm = 1
+				}
+				nx /= norm
+				ny /= norm
+				nz /= norm
+
+				eps := fs.config.VorticityStrength
+				fx[c] = eps * (ny*fs.curlZ[c] - nz*fs.curlY[c])
+				fy[c] = eps * (nz*fs.curlX[c] - nx*fs.curlZ[c])
+				fz[c] = eps * (nx*fs.curlY[c] - ny*fs.curlX[c])
+			}
+		}
+	}
+}
+
+// buoyancy calculates the buoyancy force along the y axis, like Solver.buoyancy.
+func (fs *Solver3D) buoyancy(buoy cell) {
+	var (
+		a    = fs.config.BuoyancyWeight
+		b    = fs.config.BuoyancyLift
+		tAmb = fs.config.AmbientTemperature
+	)
+	for k := 1; k <= fs.n; k++ {
+		for j := 1; j <= fs.n; j++ {
+			for i := 1; i <= fs.n; i++ {
+				c := fs.idx(i, j, k)
+				buoy[c] = a*fs.d[c] - b*(fs.t[c]-tAmb)
+			}
+		}
+	}
+}
+
+// diffuse diffuses the field between neighbouring cells.
+func (fs *Solver3D) diffuse(bound BoundaryType, x, x0 cell, diffusion float64) {
+	a := fs.dt * diffusion * float64(fs.n*fs.n)
+	fs.linearSolve(bound, x, x0, a, 1.0+6.0*a)
+}
+
+// linearSolve solves the linear system with red-black Gauss-Seidel relaxation,
+// sharing the z-slices between the workers.
+func (fs *Solver3D) linearSolve(bound BoundaryType, x, x0 cell, a, c float64) {
+	var (
+		invC = 1.0 / c
+		sx   = 1
+		sy   = fs.n + 2
+		sz   = (fs.n + 2) * (fs.n + 2)
+	)
+	for it := 0; it < fs.config.Iterations; it++ {
+		for color := 0; color < 2; color++ {
+			rows(fs.n, fs.config.Workers, func(k0, k1 int) {
+				for k := k0; k <= k1; k++ {
+					for j := 1; j <= fs.n; j++ {
+						for i := 2 - (j+k+color)%2; i <= fs.n; i += 2 {
+							c := fs.idx(i, j, k)
+							x[c] = (x0[c] + a*(x[c-sx]+x[c+sx]+x[c-sy]+x[c+sy]+x[c-sz]+x[c+sz])) * invC
+						}
+					}
+				}
+			})
+		}
+		fs.setBoundary(bound, x)
+	}
+}
+
+// project makes the velocity field mass conserving, by subtracting the gradient of the pressure.
+func (fs *Solver3D) project(u, v, w, p, div cell) {
+	var (
+		h  = 1.0 / float64(fs.n)
+		sy = fs.n + 2
+		sz = (fs.n + 2) * (fs.n + 2)
+	)
+	for k := 1; k <= fs.n; k++ {
+		for j := 1; j <= fs.n; j++ {
+			for i := 1; i <= fs.n; i++ {
+				c := fs.idx(i, j, k)
+				div[c] = -0.5 * h * (u[c+1] - u[c-1] + v[c+sy] - v[c-sy] + w[c+sz] - w[c-sz])
+				p[c] = 0
+			}
+		}
+	}
+	fs.setBoundary(BoundaryNone, div)
+	fs.setBoundary(BoundaryNone, p)
+
+	fs.linearSolve(BoundaryNone, p, div, 1, 6)
+
+	for k := 1; k <= fs.n; k++ {
+		for j := 1; j <= fs.n; j++ {
+			for i := 1; i <= fs.n; i++ {
+				c := fs.idx(i, j, k)
+				u[c] -= 0.5 * (p[c+1] - p[c-1]) / h
+				v[c] -= 0.5 * (p[c+sy] - p[c-sy]) / h
+				w[c] -= 0.5 * (p[c+sz] - p[c-sz]) / h
+			}
+		}
+	}
+	fs.setBoundary(BoundaryLeftRight, u)
+	fs.setBoundary(BoundaryTopBottom, v)
+	fs.setBoundary(boundaryFrontBack, w)
+}
+
+// advect moves the field {d0} along the velocity field with the semi-Lagrangian scheme.
+func (fs *Solver3D) advect(bound BoundaryType, d, d0, u, v, w cell) {
+	var (
+		dt0 = fs.dt * float64(fs.n)
+		max = float64(fs.n) + 0.5
+	)
+	rows(fs.n, fs.config.Workers, func(k0, k1 int) {
+		for k := k0; k <= k1; k++ {
+			for j := 1; j <= fs.n; j++ {
+				for i := 1; i <= fs.n; i++ {
+					c := fs.idx(i, j, k)
+					x := clamp(float64(i)-dt0*u[c], 0.5, max)
+					y := clamp(float64(j)-dt0*v[c], 0.5, max)
+					z := clamp(float64(k)-dt0*w[c], 0.5, max)
+					d[c] = fs.interpolate(d0, x, y, z)
+				}
+			}
+		}
+	})
+	fs.setBoundary(bound, d)
+}
+
+// interpolate returns the trilinear interpolation of {d0} at the grid position {x, y, z}.
+func (fs *Solver3D) interpolate(d0 cell, x, y, z float64) float64 {
+	i0, j0, k0 := int(x), int(y), int(z)
+
+	s1 := x - float64(i0)
+	s0 := 1 - s1
+	t1 := y - float64(j0)
+	t0 := 1 - t1
+	r1 := z - float64(k0)
+	r0 := 1 - r1
+
+	c := fs.idx(i0, j0, k0)
+	sy, sz := fs.n+2, (fs.n+2)*(fs.n+2)
+
+	return r0*(s0*(t0*d0[c]+t1*d0[c+sy])+s1*(t0*d0[c+1]+t1*d0[c+1+sy])) +
+		r1*(s0*(t0*d0[c+sz]+t1*d0[c+sy+sz])+s1*(t0*d0[c+1+sz]+t1*d0[c+1+sy+sz]))
+}
+
+// setBoundary sets the boundary cells along the closed walls. The velocity component normal
+// to a wall is mirrored, the other fields are copied from the neighbouring fluid cells.
+func (fs *Solver3D) setBoundary(bound BoundaryType, x cell) {
+	n := fs.n
+	sign := func(normal BoundaryType) float64 {
+		if bound == normal {
+			return -1
+		}
+		return 1
+	}
+	sx, sy, sz := sign(BoundaryLeftRight), sign(BoundaryTopBottom), sign(boundaryFrontBack)
+
+	for a := 1; a <= n; a++ {
+		for b := 1; b <= n; b++ {
+			x[fs.idx(0, a, b)] = sx * x[fs.idx(1, a, b)]
+			x[fs.idx(n+1, a, b)] = sx * x[fs.idx(n, a, b)]
+			x[fs.idx(a, 0, b)] = sy * x[fs.idx(a, 1, b)]
+			x[fs.idx(a, n+1, b)] = sy * x[fs.idx(a, n, b)]
+			x[fs.idx(a, b, 0)] = sz * x[fs.idx(a, b, 1)]
+			x[fs.idx(a, b, n+1)] = sz * x[fs.idx(a, b, n)]
+		}
+	}
+
+	// The edges are the average of their two neighbouring faces.
+	for a := 1; a <= n; a++ {
+		for _, p := range [2]int{0, n + 1} {
+			for _, q := range [2]int{0, n + 1} {
+				x[fs.idx(a, p, q)] = 0.5 * (x[fs.idx(a, fs.inward(p), q)] + x[fs.idx(a, p, fs.inward(q))])
+				x[fs.idx(p, a, q)] = 0.5 * (x[fs.idx(fs.inward(p), a, q)] + x[fs.idx(p, a, fs.inward(q))])
+				x[fs.idx(p, q, a)] = 0.5 * (x[fs.idx(fs.inward(p), q, a)] + x[fs.idx(p, fs.inward(q), a)])
+			}
+		}
+	}
+	// The corners are the average of their three neighbouring edges.
+	for _, i := range [2]int{0, n + 1} {
+		for _, j := range [2]int{0, n + 1} {
+			for _, k := range [2]int{0, n + 1} {
+				x[fs.idx(i, j, k)] = (x[fs.idx(fs.inward(i), j, k)] + x[fs.idx(i, fs.inward(j), k)] + x[fs.idx(i, j, fs.inward(k))]) / 3
+			}
+		}
+	}
+}
+
+// inward moves the boundary coordinate {c} one cell towards the fluid.
+func (fs *Solver3D) inward(c int) int {
+	if c == 0 {
+		return 1
+	}
+	return fs.n
+}
+
+// maxSpeed returns the highest velocity magnitude of the fluid cells.
+func (fs *Solver3D) maxSpeed() float64 {
+	var max float64
+	for c := range fs.u {
+		if s := fs.u[c]*fs.u[c] + fs.v[c]*fs.v[c] + fs.w[c]*fs.w[c]; s > max {
+			max = s
+		}
+	}
+	return math.Sqrt(max)
+}
+
+// inBounds checks if cell {x, y, z} is part of the grid, including the boundary cells.
+func (fs *Solver3D) inBounds(x, y, z int) bool {
+	return x >= 0 && x <= fs.n+1 && y >= 0 && y <= fs.n+1 && z >= 0 && z <= fs.n+1
+}
+
+// idx returns the cell's index (position).
+func (fs *Solver3D) idx(i, j, k int) int {
+	return i + (fs.n+2)*(j+(fs.n+2)*k)
+}
+
+// clamp limits {x} to the range [min, max].
+func clamp(x, min, max float64) float64 {
+	if x < min {
+		return min
+	}
+	if x > max {
+		return max
+	}
+	return x
+}
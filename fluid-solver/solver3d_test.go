@@ -0,0 +1,114 @@
+package fluid
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestSolver3DPlume(t *testing.T) {
+	const n = 16
+
+	fs := NewSolver3D(n)
+	// centerY returns the density weighted mean of the rows.
+	centerY := func() float64 {
+		var sum, mass float64
+		for k := 1; k <= n; k++ {
+			for j := 1; j <= n; j++ {
+				for i := 1; i <= n; i++ {
+					d := fs.d[fs.idx(i, j, k)]
+					sum += d * float64(j)
+					mass += d
+				}
+			}
+		}
+		return sum / mass
+	}
+
+	for step := 0; step < 30; step++ {
+		fs.AddDensity(n/2, n-2, n/2, 50)
+		fs.AddHeat(n/2, n-2, n/2, 25)
+		fs.Step(0.2)
+		if step == 0 {
+			if y := centerY(); math.Abs(y-float64(n-2)) > 1 {
+				t.Fatalf("the density is injected at row %d, but centered at %.2f", n-2, y)
+			}
+		}
+	}
+	for _, c := range []cell{fs.u, fs.v, fs.w, fs.d, fs.t} {
+		if k, ok := firstNotFinite(c); ok {
+			t.Fatalf("field is not finite at cell %d", k)
+		}
+	}
+	// The rows grow downwards, so the hot smoke rises towards the first row.
+	if y := centerY(); y > float64(n-4) {
+		t.Errorf("the hot smoke didn't rise, centered at row %.2f", y)
+	}
+}
+
+func TestSolver3DSlices(t *testing.T) {
+	const n = 8
+
+	fs := NewSolver3D(n)
+	fs.d[fs.idx(2, 3, 4)] = 1
+	fs.d[fs.idx(2, 3, 6)] = 2
+	fs.d[fs.idx(5, 5, 6)] = 3
+
+	slice, err := fs.DensitySlice(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plane := NewSolverWH(n, n)
+	if got := slice[plane.idx(2, 3)]; got != 1 {
+		t.Errorf("slice 4 at (2, 3): got %v, want 1", got)
+	}
+	if got := slice[plane.idx(5, 5)]; got != 0 {
+		t.Errorf("slice 4 at (5, 5): got %v, want 0", got)
+	}
+	if _, err := fs.DensitySlice(n + 2); err == nil {
+		t.Error("expected an error for a slice outside the grid")
+	}
+
+	proj := fs.DensityProjection()
+	if proj[plane.idx(2, 3)] != 2 || proj[plane.idx(5, 5)] != 3 {
+		t.Errorf("projection: got %v at (2, 3) and %v at (5, 5), want 2 and 3", proj[plane.idx(2, 3)], proj[plane.idx(5, 5)])
+	}
+}
+
+func TestSolver3DStepMatchesFixedStep(t *testing.T) {
+	const n = 12
+
+	cfg := DefaultConfig()
+	fixed, err := NewSolver3DConfig(n, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stepped, err := NewSolver3DConfig(n, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for step := 0; step < 5; step++ {
+		for _, fs := range []*Solver3D{fixed, stepped} {
+			fs.AddVelocity(6, 6, 6, 0.01, 0, 0.01)
+			fs.AddDensity(6, 6, 6, 50)
+			fs.AddHeat(6, 6, 6, 25)
+		}
+		fixed.VelocityStep()
+		fixed.DensityStep()
+		if got := stepped.Step(cfg.Dt); got != 1 {
+			t.Fatalf("slow flow: got %d substeps, want 1", got)
+		}
+	}
+	for _, f := range []struct {
+		name           string
+		fixed, stepped cell
+	}{
+		{"u", fixed.u, stepped.u}, {"v", fixed.v, stepped.v}, {"w", fixed.w, stepped.w},
+		{"d", fixed.d, stepped.d}, {"t", fixed.t, stepped.t},
+	} {
+		if !reflect.DeepEqual(f.fixed, f.stepped) {
+			t.Errorf("field %s: a single substep differs from the fixed time step", f.name)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package fluid
+
+import (
+	"fmt"
+	"math"
+)
+
+// splatSupport is the distance beyond which the splat kernel is cut off, relative to the radius.
+const splatSupport = 3.0
+
+// SplatDensity adds a gaussian blob of density to the fluid, with a peak of {amount} at {x, y}.
+// The position is given in normalized coordinates, where {0, 0} is the top left corner of the grid
+// and {1, 1} the bottom right one. The radius is relative to the longer side of the grid.
+// The density is injected into the fluid on the next density step.
+func (fs *Solver) SplatDensity(x, y, radius, amount float64) error {
+	return fs.splat(x, y, radius, func(k int, w float64) {
+		fs.dOld[k] += w * amount
+	})
+}
+
+// SplatVelocity adds a gaussian blob of velocity to the fluid, with a peak of {du, dv} at {x, y}.
+// The coordinates are the same as the ones of SplatDensity.
+// The velocity is injected into the fluid on the next velocity step.
+func (fs *Solver) SplatVelocity(x, y, radius, du, dv float64) error {
+	return fs.splat(x, y, radius, func(k int, w float64) {
+		fs.uOld[k] += w * du
+		fs.vOld[k] += w * dv
+	})
+}
+
+// SplatDye adds a gaussian blob of dye to the channel {c}, with a peak of {amount} at {x, y}.
+// The coordinates are the same as the ones of SplatDensity.
+// The dye is injected into the fluid on the next density step.
+func (fs *Solver) SplatDye(c int, x, y, radius, amount float64) error {
+	if c < 0 || c >= len(fs.dyes) {
+		return fmt.Errorf("%w: dye channel %d", ErrUnknownField, c)
+	}
+	return fs.splat(x, y, radius, func(k int, w float64) {
+		fs.dyes[c].dOld[k] += w * amount
+	})
+}
+
+// splat calls {fn} with the index and the kernel weight of the fluid cells covered by a gaussian
+// blob centered at the normalized position {x, y}. The kernel falls off as exp(-d²/r²).
+func (fs *Solver) splat(x, y, radius float64, fn func(k int, w float64)) error {
+	if !isFinite(x) || !isFinite(y) || !isFinite(radius) || radius <= 0 {
+		return fmt.Errorf("fluid: invalid splat at (%v, %v) with radius %v", x, y, radius)
+	}
+	// The position and the radius in cells, where the center of cell i is at i.
+	px, py := x*float64(fs.nx)+0.5, y*float64(fs.ny)+0.5
+	r := radius * fs.scale()
+	support := splatSupport * r
+	if px+support < 1 || px-support > float64(fs.nx) || py+support < 1 || py-support > float64(fs.ny) {
+		return nil
+	}
+
+	i0, i1 := int(math.Max(1, math.Floor(px-support))), int(math.Min(float64(fs.nx), math.Ceil(px+support)))
+	j0, j1 := int(math.Max(1, math.Floor(py-support))), int(math.Min(float64(fs.ny), math.Ceil(py+support)))
+	for j := j0; j <= j1; j++ {
+		for i := i0; i <= i1; i++ {
+			k := fs.idx(i, j)
+			if fs.isSolid(k) {
+				continue
+			}
+			dx, dy := float64(i)-px, float64(j)-py
+			d2 := dx*dx + dy*dy
+			if d2 > support*support {
+				continue
+			}
+			fn(k, math.Exp(-d2/(r*r)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package fluid
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// centroid returns the weighted center of the fluid cells of {x}, in cells.
+func (fs *Solver) centroid(x cell) (cx, cy, sum float64) {
+	for j := 1; j <= fs.ny; j++ {
+		for i := 1; i <= fs.nx; i++ {
+			w := x[fs.idx(i, j)]
+			cx += w * float64(i)
+			cy += w * float64(j)
+			sum += w
+		}
+	}
+	return cx / sum, cy / sum, sum
+}
+
+func TestSplatDensity(t *testing.T) {
+	fs := NewSolverWH(40, 20)
+
+	// The center of cell (10, 10), in normalized coordinates.
+	x, y := 9.5/40, 9.5/20
+	if err := fs.SplatDensity(x, y, 0.05, 2); err != nil {
+		t.Fatal(err)
+	}
+	if peak := fs.dOld[fs.idx(10, 10)]; math.Abs(peak-2) > 1e-12 {
+		t.Errorf("peak is %v, want 2", peak)
+	}
+	// The kernel is isotropic, even though the grid isn't square.
+	if a, b := fs.dOld[fs.idx(12, 10)], fs.dOld[fs.idx(10, 12)]; math.Abs(a-b) > 1e-12 {
+		t.Errorf("the blob isn't round: %v horizontally, %v vertically", a, b)
+	}
+
+	// Moving the splat by a fraction of a cell moves the blob by the same fraction.
+	for _, shift := range []float64{0.25, 0.5} {
+		fs := NewSolverWH(40, 20)
+		fs.SplatDensity(x+shift/40, y, 0.05, 2)
+		if cx, cy, _ := fs.centroid(fs.dOld); math.Abs(cx-(10+shift)) > 1e-3 || math.Abs(cy-10) > 1e-3 {
+			t.Errorf("shift %v: the blob is centered at (%.4f, %.4f), want (%v, 10)", shift, cx, cy, 10+shift)
+		}
+	}
+}
+
+func TestSplatVelocityAndDye(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DyeChannels = 1
+	fs, err := NewSolverConfig(32, 32, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.SplatVelocity(0.5, 0.5, 0.1, 0.3, -0.4); err != nil {
+		t.Fatal(err)
+	}
+	_, _, su := fs.centroid(fs.uOld)
+	_, _, sv := fs.centroid(fs.vOld)
+	if su <= 0 || math.Abs(sv/su+4.0/3) > 1e-9 {
+		t.Errorf("the velocity blob doesn't follow the direction of the splat: sums %v and %v", su, sv)
+	}
+
+	if err := fs.SplatDye(0, 0.5, 0.5, 0.1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.SplatDye(1, 0.5, 0.5, 0.1, 1); !errors.Is(err, ErrUnknownField) {
+		t.Errorf("got %v, want ErrUnknownField", err)
+	}
+	if err := fs.SplatDensity(0.5, 0.5, 0, 1); err == nil {
+		t.Error("expected an error for a zero radius")
+	}
+	// A splat off the grid doesn't touch any cell.
+	if err := fs.SplatDensity(5, 5, 0.1, 1); err != nil {
+		t.Error(err)
+	}
+	if _, _, sum := fs.centroid(fs.dOld); sum != 0 {
+		t.Errorf("the splat off the grid added %v density", sum)
+	}
+}
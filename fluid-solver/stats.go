@@ -0,0 +1,93 @@
+package fluid
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrNotFinite is returned when a field of the solver contains NaN or infinite values.
+var ErrNotFinite = errors.New("fluid: field is not finite")
+
+// Stats holds the diagnostics of the simulation state. The totals are summed over the fluid cells.
+type Stats struct {
+	// MaxDivergence is the highest absolute divergence of the velocity field.
+	// After a simulation step it measures how well the pressure projection did.
+	MaxDivergence float64
+	// Mass is the total smoke density.
+	Mass float64
+	// KineticEnergy is the half of the squared velocity magnitudes.
+	KineticEnergy float64
+	// Enstrophy is the half of the squared vorticity.
+	Enstrophy float64
+	// MaxSpeed is the highest velocity magnitude.
+	MaxSpeed float64
+
+	// PressureIterations is the iteration count of the last pressure solve.
+	PressureIterations int
+	// PressureResidual is the final residual of the last pressure solve.
+	PressureResidual float64
+}
+
+// Stats returns the diagnostics of the current simulation state.
+func (fs *Solver) Stats() Stats {
+	st := Stats{
+		PressureIterations: fs.pressureIterations,
+		PressureResidual:   fs.pressureResidual,
+	}
+	scale := fs.scale()
+
+	for j := 1; j <= fs.ny; j++ {
+		for i := 1; i <= fs.nx; i++ {
+			k := fs.idx(i, j)
+			if fs.isSolid(k) {
+				continue
+			}
+			div := scale * fs.divergence(fs.u, fs.v, i, j)
+			st.MaxDivergence = math.Max(st.MaxDivergence, math.Abs(div))
+
+			speed := fs.u[k]*fs.u[k] + fs.v[k]*fs.v[k]
+			st.MaxSpeed = math.Max(st.MaxSpeed, speed)
+			st.KineticEnergy += 0.5 * speed
+
+			curl := fs.curl(i, j) * scale
+			st.Enstrophy += 0.5 * curl * curl
+
+			st.Mass += fs.d[k]
+		}
+	}
+	st.MaxSpeed = math.Sqrt(st.MaxSpeed)
+
+	return st
+}
+
+// CheckFinite returns an error wrapping ErrNotFinite if any field contains NaN or infinite values,
+// which happens when the simulation blows up, e.g. because of an unstable parameter set.
+// The error names the first such field and cell.
+func (fs *Solver) CheckFinite() error {
+	for f := FieldU; f <= FieldTOld; f++ {
+		c, err := fs.field(f)
+		if err != nil {
+			return err
+		}
+		if k, ok := firstNotFinite(c); ok {
+			return fmt.Errorf("%w: %v at (%d, %d)", ErrNotFinite, f, k%(fs.nx+2), k/(fs.nx+2))
+		}
+	}
+	for c, dy := range fs.dyes {
+		if k, ok := firstNotFinite(dy.d); ok {
+			return fmt.Errorf("%w: dye %d at (%d, %d)", ErrNotFinite, c, k%(fs.nx+2), k/(fs.nx+2))
+		}
+	}
+	return nil
+}
+
+// firstNotFinite returns the index of the first NaN or infinite value of {c}.
+func firstNotFinite(c cell) (int, bool) {
+	for k, x := range c {
+		if !isFinite(x) {
+			return k, true
+		}
+	}
+	return 0, false
+}
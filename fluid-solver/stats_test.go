@@ -0,0 +1,44 @@
+package fluid
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	fs := NewSolver(16)
+	fs.Set(FieldD, 4, 4, 2)
+	fs.Set(FieldD, 9, 3, 3)
+	fs.Set(FieldU, 8, 8, 0.5)
+
+	st := fs.Stats()
+	if st.Mass != 5 {
+		t.Errorf("mass: got %v, want 5", st.Mass)
+	}
+	if st.MaxSpeed != 0.5 {
+		t.Errorf("max speed: got %v, want 0.5", st.MaxSpeed)
+	}
+	if st.KineticEnergy != 0.125 {
+		t.Errorf("kinetic energy: got %v, want 0.125", st.KineticEnergy)
+	}
+	if st.MaxDivergence == 0 || st.Enstrophy == 0 {
+		t.Errorf("a single moving cell must have divergence and vorticity: %+v", st)
+	}
+
+	fs.Step(fs.Config().Dt)
+	if st := fs.Stats(); st.PressureIterations == 0 || st.PressureResidual == 0 {
+		t.Errorf("missing pressure solve stats: %+v", st)
+	}
+}
+
+func TestCheckFinite(t *testing.T) {
+	fs := NewSolver(16)
+	if err := fs.CheckFinite(); err != nil {
+		t.Fatal(err)
+	}
+	fs.Set(FieldVOld, 3, 5, math.Inf(1))
+	if err := fs.CheckFinite(); !errors.Is(err, ErrNotFinite) {
+		t.Errorf("got %v, want ErrNotFinite", err)
+	}
+}
@@ -0,0 +1,83 @@
+package fluid
+
+import "math"
+
+// Step advances the simulation by {dt}, running both the velocity and the density step.
+// The time is split into equal substeps, so the fastest fluid cell doesn't travel more than
+// the CFL number of the configuration in a single substep, but at most MaxSubsteps are run.
+// The sources and the emitters are applied over the whole time step: the velocity sources before
+// the first substep, and the density, the heat and the dye sources after its velocity step, so the
+// buoyancy doesn't see the new heat early, like with VelocityStep and DensityStep.
+// It returns the number of the substeps run.
+func (fs *Solver) Step(dt float64) int {
+	if !isFinite(dt) || dt <= 0 {
+		return 0
+	}
+	fs.dt = dt
+	fs.emit(dt)
+	fs.addVelocitySources()
+
+	n := fs.substeps(dt)
+	fs.dt = dt / float64(n)
+	for s := 0; s < n; s++ {
+		fs.velocityStep()
+		if s == 0 {
+			fs.dt = dt
+			fs.addScalarSources()
+			fs.dt = dt / float64(n)
+		}
+		fs.densityStep()
+	}
+	return n
+}
+
+// substeps returns the number of the substeps needed to advance the simulation by {dt}.
+func (fs *Solver) substeps(dt float64) int {
+	cells := dt * fs.maxSpeed() * fs.scale()
+	n := int(math.Ceil(cells / fs.config.CFL))
+	if n < 1 {
+		n = 1
+	}
+	if n > fs.config.MaxSubsteps {
+		n = fs.config.MaxSubsteps
+	}
+	return n
+}
+
+// maxSpeed returns the highest velocity magnitude of the fluid cells.
+func (fs *Solver) maxSpeed() float64 {
+	var max float64
+	for j := 1; j <= fs.ny; j++ {
+		for i := 1; i <= fs.nx; i++ {
+			k := fs.idx(i, j)
+			if s := fs.u[k]*fs.u[k] + fs.v[k]*fs.v[k]; s > max {
+				max = s
+			}
+		}
+	}
+	return math.Sqrt(max)
+}
+
+// addVelocitySources adds the velocity sources over the current time step and clears them.
+func (fs *Solver) addVelocitySources() {
+	fs.flushSources([]struct{ x, s cell }{{fs.u, fs.uOld}, {fs.v, fs.vOld}})
+}
+
+// addScalarSources adds the density, the heat and the dye sources over the current time step and clears them.
+func (fs *Solver) addScalarSources() {
+	sources := []struct{ x, s cell }{{fs.d, fs.dOld}, {fs.t, fs.tOld}}
+	for _, dy := range fs.dyes {
+		sources = append(sources, struct{ x, s cell }{dy.d, dy.dOld})
+	}
+	fs.flushSources(sources)
+}
+
+// flushSources adds the sources to their fields over the current time step and clears them.
+func (fs *Solver) flushSources(sources []struct{ x, s cell }) {
+	for _, src := range sources {
+		fs.addSource(src.x, src.s)
+		for i := range src.s {
+			src.s[i] = 0
+		}
+	}
+}
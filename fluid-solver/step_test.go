@@ -0,0 +1,59 @@
+package fluid
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStepMatchesFixedStep(t *testing.T) {
+	cfg := DefaultConfig()
+
+	fixed, err := NewSolverConfig(32, 16, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stepped, err := NewSolverConfig(32, 16, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for step := 0; step < 5; step++ {
+		for _, fs := range []*Solver{fixed, stepped} {
+			fs.Set(FieldUOld, 8, 8, 0.01)
+			fs.Set(FieldDOld, 8, 8, 50)
+			fs.Set(FieldTOld, 8, 8, 25)
+		}
+		fixed.VelocityStep()
+		fixed.DensityStep()
+		if n := stepped.Step(cfg.Dt); n != 1 {
+			t.Fatalf("slow flow: got %d substeps, want 1", n)
+		}
+	}
+	if !reflect.DeepEqual(fixed.snapshotFields(), stepped.snapshotFields()) {
+		t.Error("a single substep differs from the fixed time step")
+	}
+}
+
+func TestStepSubsteps(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.CFL = 1
+	cfg.MaxSubsteps = 4
+
+	fs, err := NewSolverConfig(10, 10, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := fs.Step(0); n != 0 {
+		t.Errorf("zero time step: got %d substeps, want 0", n)
+	}
+
+	// The fluid travels 2.5 cells over the time step.
+	fs.Set(FieldU, 5, 5, 0.25)
+	if n := fs.substeps(1); n != 3 {
+		t.Errorf("got %d substeps, want 3", n)
+	}
+	fs.Set(FieldU, 5, 5, 10)
+	if n := fs.substeps(1); n != cfg.MaxSubsteps {
+		t.Errorf("fast flow: got %d substeps, want %d", n, cfg.MaxSubsteps)
+	}
+}
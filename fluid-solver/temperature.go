@@ -0,0 +1,45 @@
+package fluid
+
+// Temperature returns a read-only view of the temperature field.
+// The view is only valid until the next simulation step.
+func (fs *Solver) Temperature() []float64 {
+	return fs.t
+}
+
+// AddHeat adds {amount} of heat to cell {x, y}, independently of the density.
+// The heat is injected into the fluid on the next density step.
+func (fs *Solver) AddHeat(x, y int, amount float64) error {
+	val, err := fs.Get(FieldTOld, x, y)
+	if err != nil {
+		return err
+	}
+	return fs.Set(FieldTOld, x, y, val+amount)
+}
+
+// ResetTemperature sets the temperature of all the cells to the ambient temperature.
+func (fs *Solver) ResetTemperature() {
+	for i := 0; i < fs.numOfCells; i++ {
+		fs.t[i] = fs.config.AmbientTemperature
+	}
+}
+
+// temperatureStep moves the temperature along the velocity field and cools it down towards the ambient temperature.
+func (fs *Solver) temperatureStep() {
+	fs.addSource(fs.t, fs.tOld)
+
+	fs.t, fs.tOld = fs.tOld, fs.t
+	fs.diffuse(BoundaryNone, fs.t, fs.tOld, fs.config.TemperatureDiffusion)
+
+	fs.t, fs.tOld = fs.tOld, fs.t
+	fs.advect(BoundaryNone, fs.t, fs.tOld, fs.u, fs.v)
+
+	cooling := fs.config.Cooling * fs.dt
+	if cooling > 1 {
+		cooling = 1
+	}
+	for i := 0; i < fs.numOfCells; i++ {
+		fs.t[i] -= cooling * (fs.t[i] - fs.config.AmbientTemperature)
+		// reset for the next step
+		fs.tOld[i] = 0
+	}
+}
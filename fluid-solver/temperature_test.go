@@ -0,0 +1,86 @@
+package fluid
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTemperatureBuoyancy(t *testing.T) {
+	const n = 24
+
+	for _, c := range []struct {
+		name      string
+		row, heat float64
+	}{
+		{"hot", n - 3, 25},
+		{"cold", 4, -25},
+	} {
+		cfg := DefaultConfig()
+		cfg.AmbientTemperature = 20
+		fs, err := NewSolverConfig(n, n, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// centerY returns the mean of the rows weighted with the difference from the ambient temperature.
+		centerY := func() float64 {
+			var sum, mass float64
+			for j := 1; j <= n; j++ {
+				for i := 1; i <= n; i++ {
+					d := math.Abs(fs.t[fs.idx(i, j)] - cfg.AmbientTemperature)
+					sum += d * float64(j)
+					mass += d
+				}
+			}
+			return sum / mass
+		}
+
+		for step := 0; step < 30; step++ {
+			fs.AddHeat(n/2, int(c.row), c.heat)
+			fs.Step(0.2)
+			if step == 0 {
+				if y := centerY(); math.Abs(y-c.row) > 1 {
+					t.Fatalf("%s: the heat is injected at row %v, but centered at %.2f", c.name, c.row, y)
+				}
+			}
+		}
+		if err := fs.CheckFinite(); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		// The rows grow downwards, so the hot air rises towards the first row and the cold one sinks.
+		y := centerY()
+		if c.heat > 0 && y > c.row-2 {
+			t.Errorf("the hot air didn't rise from row %v, centered at row %.2f", c.row, y)
+		}
+		if c.heat < 0 && y < c.row+2 {
+			t.Errorf("the cold air didn't sink from row %v, centered at row %.2f", c.row, y)
+		}
+	}
+}
+
+func TestTemperatureCooling(t *testing.T) {
+	const steps = 20
+
+	cfg := DefaultConfig()
+	cfg.AmbientTemperature = 20
+	cfg.Buoyancy = false
+	fs, err := NewSolverConfig(16, 16, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k := range fs.t {
+		fs.t[k] = 30
+	}
+	for step := 0; step < steps; step++ {
+		fs.Step(0.2)
+	}
+
+	// The still air cools down exponentially towards the ambient temperature.
+	want := 20 + 10*math.Pow(1-cfg.Cooling*0.2, steps)
+	for j := 1; j <= 16; j++ {
+		for i := 1; i <= 16; i++ {
+			if got := fs.t[fs.idx(i, j)]; math.Abs(got-want) > 1e-9 {
+				t.Fatalf("the temperature of cell (%d, %d) is %v, want %v", i, j, got, want)
+			}
+		}
+	}
+}
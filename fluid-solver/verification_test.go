@@ -0,0 +1,258 @@
+package fluid
+
+import (
+	"math"
+	"testing"
+)
+
+// ghiaU is the horizontal velocity along the vertical centerline of the lid-driven cavity at Re = 100,
+// from Ghia, Ghia and Shin (1982), as {y, u} pairs with the lid at y = 1.
+var ghiaU = [][2]float64{
+	{0.9766, 0.84123}, {0.9688, 0.78871}, {0.9609, 0.73722}, {0.9531, 0.68717},
+	{0.8516, 0.23151}, {0.7344, 0.00332}, {0.6172, -0.13641}, {0.5000, -0.20581},
+	{0.4531, -0.21090}, {0.2813, -0.15662}, {0.1719, -0.10150}, {0.1016, -0.06434},
+	{0.0703, -0.04775}, {0.0625, -0.04192}, {0.0547, -0.03717},
+}
+
+// ghiaV is the vertical velocity along the horizontal centerline of the lid-driven cavity at Re = 100,
+// from Ghia, Ghia and Shin (1982), as {x, v} pairs with the lid moving towards x = 1.
+var ghiaV = [][2]float64{
+	{0.9688, -0.05906}, {0.9609, -0.07391}, {0.9531, -0.08864}, {0.9453, -0.10313},
+	{0.9063, -0.16914}, {0.8594, -0.22445}, {0.8047, -0.24533}, {0.5000, 0.05454},
+	{0.2344, 0.17527}, {0.2266, 0.17507}, {0.1563, 0.16077}, {0.0938, 0.12317},
+	{0.0781, 0.10890}, {0.0703, 0.10091}, {0.0625, 0.09233},
+}
+
+// flowConfig returns a configuration without the artificial forces, for comparing with the analytic flows.
+func flowConfig(dt, viscosity float64, edges Edges) SolverConfig {
+	cfg := DefaultConfig()
+	cfg.Dt = dt
+	cfg.Viscosity = viscosity
+	cfg.Diffusion = 0
+	cfg.Iterations = 40
+	cfg.Vorticity = false
+	cfg.Buoyancy = false
+	cfg.Edges = edges
+	return cfg
+}
+
+// periodicEdges returns the edges of a fully periodic domain.
+func periodicEdges() Edges {
+	periodic := Edge{Type: EdgePeriodic}
+	return Edges{Left: periodic, Right: periodic, Top: periodic, Bottom: periodic}
+}
+
+// position returns the coordinates of the cell (i, j) center in the unit domain.
+func (fs *Solver) position(i, j int) (x, y float64) {
+	h := 1 / fs.scale()
+	return (float64(i) - 0.5) * h, (float64(j) - 0.5) * h
+}
+
+func TestLidDrivenCavity(t *testing.T) {
+	if testing.Short() {
+		t.Skip("the cavity needs a long time to reach the steady state")
+	}
+	const (
+		n  = 32
+		re = 100.0
+	)
+	wall := Edge{Type: EdgeNoSlip}
+	// The top edge is the lid, which is at y = 1 in the reference coordinates.
+	edges := Edges{Left: wall, Right: wall, Top: Edge{Type: EdgeNoSlip, U: 1}, Bottom: wall}
+
+	fs, err := NewSolverConfig(n, n, flowConfig(0.02, 1/re, edges))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.SetPressureSolver(&ConjugateGradient{Tolerance: 1e-6})
+	for step := 0; step < 1000; step++ {
+		fs.VelocityStep()
+	}
+
+	// sample interpolates the field {c} at the reference coordinates {x, y}.
+	sample := func(c cell, x, y float64) float64 {
+		return fs.interpolate(c, x*n+0.5, (1-y)*n+0.5)
+	}
+	var maxErr float64
+	for _, ref := range ghiaU {
+		u := sample(fs.u, 0.5, ref[0])
+		t.Logf("u(0.5, %.4f) = %+.4f, reference %+.4f", ref[0], u, ref[1])
+		maxErr = math.Max(maxErr, math.Abs(u-ref[1]))
+	}
+	for _, ref := range ghiaV {
+		// The reference y axis points up, while the rows of the grid go down.
+		v := -sample(fs.v, ref[0], 0.5)
+		t.Logf("v(%.4f, 0.5) = %+.4f, reference %+.4f", ref[0], v, ref[1])
+		maxErr = math.Max(maxErr, math.Abs(v-ref[1]))
+	}
+	t.Logf("max error %.4f", maxErr)
+	if maxErr > 0.04 {
+		t.Errorf("centerline velocities differ from the reference by %.4f", maxErr)
+	}
+}
+
+// taylorGreen sets the velocity field of the Taylor-Green vortex with wavenumber {k} and amplitude {amp}.
+func (fs *Solver) taylorGreen(k, amp float64) {
+	for j := 1; j <= fs.ny; j++ {
+		for i := 1; i <= fs.nx; i++ {
+			x, y := fs.position(i, j)
+			fs.u[fs.idx(i, j)] = amp * math.Sin(k*x) * math.Cos(k*y)
+			fs.v[fs.idx(i, j)] = -amp * math.Cos(k*x) * math.Sin(k*y)
+		}
+	}
+	fs.setBoundary(BoundaryLeftRight, fs.u)
+	fs.setBoundary(BoundaryTopBottom, fs.v)
+}
+
+func TestTaylorGreenDecay(t *testing.T) {
+	const (
+		n     = 64
+		dt    = 0.05
+		steps = 100
+		k     = 2 * math.Pi
+	)
+	for _, viscosity := range []float64{0.0005, 0.001, 0.002} {
+		fs, err := NewSolverConfig(n, n, flowConfig(dt, viscosity, periodicEdges()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs.taylorGreen(k, 0.005)
+		e0 := fs.Stats().KineticEnergy
+		for step := 0; step < steps; step++ {
+			fs.VelocityStep()
+		}
+
+		// The kinetic energy decays as exp(-4 nu k^2 t).
+		rate := -math.Log(fs.Stats().KineticEnergy/e0) / (dt * steps)
+		want := 4 * viscosity * k * k
+		t.Logf("viscosity %v: decay rate %.5f, analytic %.5f", viscosity, rate, want)
+		if math.Abs(rate-want) > 0.05*want {
+			t.Errorf("viscosity %v: decay rate %.5f differs from the analytic %.5f", viscosity, rate, want)
+		}
+	}
+}
+
+func TestMassConservation(t *testing.T) {
+	// blob fills the density field with a gaussian blob.
+	blob := func(fs *Solver) {
+		for j := 1; j <= fs.ny; j++ {
+			for i := 1; i <= fs.nx; i++ {
+				x, y := fs.position(i, j)
+				fs.d[fs.idx(i, j)] = math.Exp(-((x-0.3)*(x-0.3) + (y-0.4)*(y-0.4)) / 0.01)
+			}
+		}
+	}
+
+	// A uniform flow around the periodic domain moves the density without losing any of it.
+	fs, err := NewSolverConfig(48, 48, flowConfig(0.1, 0, periodicEdges()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob(fs)
+	for k := range fs.u {
+		fs.u[k], fs.v[k] = 0.07, -0.03
+	}
+	mass := fs.Stats().Mass
+	for step := 0; step < 200; step++ {
+		fs.DensityStep()
+	}
+	if got := fs.Stats().Mass; math.Abs(got-mass) > 1e-9*mass {
+		t.Errorf("uniform flow: mass changed from %.5f to %.5f", mass, got)
+	}
+
+	// The bilinear interpolation of the semi-Lagrangian advection doesn't conserve the mass
+	// exactly in a rotating flow, but the loss must shrink with the grid resolution.
+	var prevLoss float64
+	for _, n := range []int{32, 64, 128} {
+		fs, err := NewSolverConfig(n, n, flowConfig(0.1, 0, periodicEdges()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		blob(fs)
+		fs.taylorGreen(2*math.Pi, 0.05)
+		mass := fs.Stats().Mass
+
+		// The velocity field is kept frozen, only the density moves.
+		for step := 0; step < 50; step++ {
+			fs.DensityStep()
+		}
+		loss := 1 - fs.Stats().Mass/mass
+		t.Logf("vortex flow on %dx%d grid: %.2f%% of the mass lost", n, n, 100*loss)
+		if prevLoss != 0 && math.Abs(loss) > 0.6*math.Abs(prevLoss) {
+			t.Errorf("vortex flow on %dx%d grid: the mass loss doesn't converge", n, n)
+		}
+		prevLoss = loss
+	}
+}
+
+func TestProjectDivergenceFree(t *testing.T) {
+	const n = 48
+
+	for _, ps := range []PressureSolver{&GaussSeidel{Tolerance: 1e-10, MaxIterations: 10000}, &ConjugateGradient{Tolerance: 1e-10}, &Multigrid{Tolerance: 1e-10}} {
+		fs, err := NewSolverConfig(n, n, flowConfig(0.1, 0, Edges{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs.SetPressureSolver(ps)
+		for j := 1; j <= n; j++ {
+			for i := 1; i <= n; i++ {
+				x, y := fs.position(i, j)
+				// The velocity normal to the walls is zero, but the field is not divergence free.
+				fs.u[fs.idx(i, j)] = math.Sin(math.Pi*x) * math.Cos(math.Pi*y)
+				fs.v[fs.idx(i, j)] = math.Sin(math.Pi*y) * math.Cos(2*math.Pi*x)
+			}
+		}
+		fs.setBoundary(BoundaryLeftRight, fs.u)
+		fs.setBoundary(BoundaryTopBottom, fs.v)
+
+		before := fs.Stats().MaxDivergence
+		fs.project(fs.u, fs.v, fs.uOld, fs.vOld)
+		after := fs.Stats().MaxDivergence
+		t.Logf("%T: max divergence %.2e before, %.2e after the projection", ps, before, after)
+
+		// The central differences of the velocity don't match the pressure stencil exactly,
+		// which leaves a divergence of the order of the discretization error.
+		if after > 0.01*before {
+			t.Errorf("%T: max divergence %.2e after the projection, %.2e before", ps, after, before)
+		}
+	}
+}
+
+func TestCurl(t *testing.T) {
+	const (
+		n   = 64
+		k   = 2 * math.Pi
+		amp = 0.1
+	)
+	fs, err := NewSolverConfig(n, n, flowConfig(0.1, 0, periodicEdges()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.taylorGreen(k, amp)
+
+	var maxErr float64
+	for j := 1; j <= n; j++ {
+		for i := 1; i <= n; i++ {
+			x, y := fs.position(i, j)
+			want := -2 * amp * k * math.Sin(k*x) * math.Sin(k*y)
+			maxErr = math.Max(maxErr, math.Abs(fs.curl(i, j)*n-want))
+		}
+	}
+	// The central differences are second order accurate.
+	if maxErr > 0.01*2*amp*k {
+		t.Errorf("curl differs from the analytic vorticity by %.2e", maxErr)
+	}
+
+	// A uniform flow has no vorticity, so there is nothing to confine.
+	for k := range fs.u {
+		fs.u[k], fs.v[k] = 0.3, -0.2
+	}
+	fs.calcVorticityConfinement(fs.uOld, fs.vOld)
+	for j := 1; j <= n; j++ {
+		for i := 1; i <= n; i++ {
+			if fx, fy := fs.uOld[fs.idx(i, j)], fs.vOld[fs.idx(i, j)]; fx != 0 || fy != 0 {
+				t.Fatalf("vorticity confinement force (%v, %v) in uniform flow at (%d, %d)", fx, fy, i, j)
+			}
+		}
+	}
+}
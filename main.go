@@ -1,10 +1,36 @@
 package main
 
 import (
+	"flag"
+
 	"github.com/esimov/ascii-fluid/terminal"
 )
 
+var (
+	ramp      = flag.String("ramp", terminal.DefaultRamp, "Characters used for rendering the density field, from the lowest to the highest intensity")
+	boundary  = flag.String("boundary", "closed", "Boundary conditions of the grid edges: closed, periodic or tunnel")
+	advection = flag.String("advection", "sl", "Advection scheme: sl (semi-Lagrangian), maccormack or bfecc")
+	snapshot  = flag.String("snapshot", "ascii-fluid.snapshot", "File used for saving and loading the simulation state")
+	autosave  = flag.Duration("autosave", 0, "Interval of saving the simulation state periodically (e.g. 30s), zero disables it")
+	scene     = flag.String("scene", "none", "Scene preset placing emitters on the grid: none, plume or jets")
+	threeD    = flag.Bool("3d", false, "Run the 3D solver, showing a slice or a projection of the volume")
+	solver    = flag.String("solver", "grid", "2D solver: grid, or flip for the FLIP solver carrying the velocity on particles")
+	blend     = flag.Float64("blend", terminal.DefaultFlipBlend, "PIC/FLIP blend of the FLIP solver, from 0 (PIC) to 1 (FLIP)")
+)
+
 func main() {
-	term := terminal.New()
+	flag.Parse()
+
+	term := terminal.New(&terminal.Params{
+		Ramp:      *ramp,
+		Boundary:  *boundary,
+		Advection: *advection,
+		Snapshot:  *snapshot,
+		Autosave:  *autosave,
+		Scene:     *scene,
+		ThreeD:    *threeD,
+		Solver:    *solver,
+		FlipBlend: *blend,
+	})
 	term.Init().Render()
 }
@@ -0,0 +1,85 @@
+package terminal
+
+import (
+	"math"
+
+	"github.com/gdamore/tcell"
+)
+
+// colorMode is the color depth used for rendering the dye.
+type colorMode int
+
+const (
+	colorMono colorMode = iota
+	color16
+	color256
+	colorTrue
+)
+
+// dyeChannels is the number of the dye channels: red, green and blue.
+const dyeChannels = 3
+
+var (
+	// The dye colors injected by the left and right mouse buttons and the detected face.
+	leftButtonDye  = [dyeChannels]float64{0.2, 0.8, 1.0}
+	rightButtonDye = [dyeChannels]float64{1.0, 0.45, 0.1}
+	faceDye        = [dyeChannels]float64{0.9, 0.2, 0.8}
+
+	// palette16 caches the closest 16 color matches of the 256 color cube.
+	palette16 = make(map[tcell.Color]tcell.Color)
+)
+
+// detectColorMode returns the best color mode supported by the screen.
+func detectColorMode(s tcell.Screen) colorMode {
+	switch n := s.Colors(); {
+	case n >= 1<<24:
+		return colorTrue
+	case n >= 256:
+		return color256
+	case n >= 8:
+		return color16
+	}
+	return colorMono
+}
+
+// rgbColor converts the color components in range [0, 1] into the closest color of the color mode.
+func (t *Terminal) rgbColor(r, g, b float64) tcell.Color {
+	switch t.colors {
+	case colorTrue:
+		return tcell.NewRGBColor(colorByte(r), colorByte(g), colorByte(b))
+	case color256:
+		return cubeColor(r, g, b)
+	case color16:
+		c := cubeColor(r, g, b)
+		if match, ok := palette16[c]; ok {
+			return match
+		}
+		palette := make([]tcell.Color, 16)
+		for i := range palette {
+			palette[i] = tcell.Color(i)
+		}
+		match := tcell.FindColor(c, palette)
+		palette16[c] = match
+
+		return match
+	}
+	return tcell.ColorDefault
+}
+
+// cubeColor returns the closest color of the 6x6x6 color cube of the 256 color palette.
+func cubeColor(r, g, b float64) tcell.Color {
+	level := func(c float64) int {
+		return int(math.Round(clamp(c, 0, 1) * 5))
+	}
+	return tcell.Color(16 + 36*level(r) + 6*level(g) + level(b))
+}
+
+// colorByte converts a color component in range [0, 1] into the range [0, 255].
+func colorByte(c float64) int32 {
+	return int32(math.Round(clamp(c, 0, 1) * 255))
+}
+
+// clamp limits {x} to the range [min, max].
+func clamp(x, min, max float64) float64 {
+	return math.Max(min, math.Min(max, x))
+}
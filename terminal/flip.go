@@ -0,0 +1,56 @@
+package terminal
+
+import (
+	"fmt"
+	"math"
+
+	fluid "github.com/esimov/ascii-fluid/fluid-solver"
+)
+
+const (
+	// flipParticlesPerCell is the number of the particles seeded into each fluid cell of the FLIP solver.
+	flipParticlesPerCell = 4
+	// flipSpeedScale is the particle speed mapped to the last character of the ramp, in terminal cells per second.
+	flipSpeedScale = 40.0
+)
+
+// initFlip wraps the grid solver into a FLIP solver with the PIC/FLIP {blend},
+// when it's selected by the solver {name}. The grid solver is used as is otherwise.
+func (t *Terminal) initFlip(name string, blend float64) error {
+	switch name {
+	case "", "grid":
+		return nil
+	case "flip":
+		var err error
+		t.flip, err = fluid.NewFLIP(t.fs, flipParticlesPerCell, blend)
+		return err
+	}
+	return fmt.Errorf("unknown solver: %q", name)
+}
+
+// drawFlipParticles draws the particles of the FLIP solver in place of the density field.
+// The faster particles get the denser characters of the ramp, colored by the dye below them,
+// while the resting ones are not drawn.
+func (t *Terminal) drawFlipParticles() {
+	var dyes [dyeChannels][]float64
+	for c := range dyes {
+		dyes[c], _ = t.fs.Dye(c)
+	}
+	ramp := t.opts.ramp
+	levels := float64(len(ramp) - 1)
+
+	for _, p := range t.flip.Particles() {
+		speed := math.Hypot(p.GetVx()*float64(termWidth), p.GetVy()*float64(termHeight)) * timeScale
+		level := int(speed / flipSpeedScale * levels)
+		if level <= 0 {
+			continue
+		}
+		if level > len(ramp)-1 {
+			level = len(ramp) - 1
+		}
+		x, y := int(p.GetX()*float64(termWidth)), int(p.GetY()*float64(termHeight))
+		i := int(math.Min(p.GetX()*float64(gridWidth), float64(gridWidth-1))) + 1
+		j := int(math.Min(p.GetY()*float64(gridHeight), float64(gridHeight-1))) + 1
+		t.screen.SetContent(x, y, ramp[level], nil, t.dyeStyle(dyes, i+(gridWidth+2)*j))
+	}
+}
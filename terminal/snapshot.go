@@ -0,0 +1,123 @@
+package terminal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	fluid "github.com/esimov/ascii-fluid/fluid-solver"
+)
+
+const (
+	// defaultSnapshot is the file used for saving and loading the simulation state.
+	defaultSnapshot = "ascii-fluid.snapshot"
+	// statusTimeout is the time the status messages are shown for.
+	statusTimeout = 3 * time.Second
+)
+
+// errVolumeSnapshot is returned when saving or loading a snapshot of the 3D solver.
+var errVolumeSnapshot = errors.New("snapshots are not supported by the 3D solver")
+
+var (
+	statusMsg  string
+	statusTime time.Time
+)
+
+// snapshotPath returns the file used for saving and loading the simulation state.
+func (t *Terminal) snapshotPath() string {
+	if t.params != nil && t.params.Snapshot != "" {
+		return t.params.Snapshot
+	}
+	return defaultSnapshot
+}
+
+// saveSnapshot writes the fluid solver state into the snapshot file. The state is written
+// into a temporary file first, so a failing write doesn't destroy the previous snapshot.
+func (t *Terminal) saveSnapshot() error {
+	if t.fs3 != nil {
+		return errVolumeSnapshot
+	}
+	path := t.snapshotPath()
+	tmp := path + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := t.fs.Snapshot(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadSnapshot replaces the fluid solver with the state read from the snapshot file.
+func (t *Terminal) loadSnapshot() error {
+	if t.fs3 != nil {
+		return errVolumeSnapshot
+	}
+	f, err := os.Open(t.snapshotPath())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fs, err := fluid.Restore(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	// The emitters are not part of the snapshot, so they are handed over to the new solver.
+	for _, e := range t.fs.Emitters() {
+		if err := fs.AddEmitter(e); err != nil {
+			return err
+		}
+	}
+	if t.flip != nil {
+		// The particles are seeded again, taking the velocity of the restored grid.
+		flip, err := fluid.NewFLIP(fs, flipParticlesPerCell, t.flip.Blend())
+		if err != nil {
+			return err
+		}
+		t.flip = flip
+	}
+	// The old solver lets them go, and they are moved to the same relative position on the
+	// restored grid, like the emitters of a resized solver.
+	onx, ony := t.fs.Size()
+	nx, ny := fs.Size()
+	for _, e := range fs.Emitters() {
+		e.X = (e.X-0.5)*float64(nx)/float64(onx) + 0.5
+		e.Y = (e.Y-0.5)*float64(ny)/float64(ony) + 0.5
+	}
+	t.fs.ClearEmitters()
+	t.fs = fs
+	gridWidth, gridHeight = nx, ny
+	cellSize = termWidth / gridWidth
+
+	return nil
+}
+
+// setStatus shows a status message at the bottom of the screen. In case of an error the error is shown instead.
+func setStatus(msg string, err error) {
+	if err != nil {
+		msg = fmt.Sprintf("error: %v", err)
+	}
+	statusMsg = msg
+	statusTime = time.Now()
+}
+
+// drawStatus draws the last status message, until it times out.
+func (t *Terminal) drawStatus() {
+	if statusMsg != "" && time.Since(statusTime) < statusTimeout {
+		debug(t.screen, 1, termHeight-1, termStyle, statusMsg)
+	}
+}
@@ -7,11 +7,11 @@ import (
 	"fmt"
 	"log"
 	"math"
-	"math/rand"
 	"net"
 	"os"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	fluid "github.com/esimov/ascii-fluid/fluid-solver"
 	"github.com/esimov/ascii-fluid/websocket"
@@ -25,7 +25,42 @@ import (
 type Terminal struct {
 	screen tcell.Screen
 	fs     *fluid.Solver
+	fs3    *fluid.Solver3D
+	flip   *fluid.FLIP
+	mouse  *fluid.Emitter
+	face   *fluid.Emitter
+	ps     *fluid.ParticleSystem
 	opts   *options
+	params *Params
+	colors colorMode
+
+	// mouseParticles and faceParticles spawn the particles following the mouse and the face.
+	mouseParticles *fluid.ParticleEmitter
+	faceParticles  *fluid.ParticleEmitter
+}
+
+// Params holds the terminal parameters which can be set at startup.
+type Params struct {
+	// Ramp is the list of characters used for rendering the density field,
+	// ordered from the lowest to the highest intensity. It needs at least two characters,
+	// and the empty ramp is replaced by DefaultRamp.
+	Ramp string
+	// Boundary is the name of the boundary preset: closed, periodic or tunnel.
+	Boundary string
+	// Advection is the name of the advection scheme: sl, maccormack or bfecc.
+	Advection string
+	// Snapshot is the file used for saving and loading the simulation state.
+	Snapshot string
+	// Autosave is the interval of saving the simulation state periodically. Zero disables it.
+	Autosave time.Duration
+	// Scene is the name of the scene preset, placing emitters on the grid: none, plume or jets.
+	Scene string
+	// ThreeD runs the three dimensional solver, showing a slice or a projection of the volume.
+	ThreeD bool
+	// Solver is the name of the 2D solver: grid, or flip for the FLIP solver carrying the velocity on particles.
+	Solver string
+	// FlipBlend is the PIC/FLIP blend of the FLIP solver, from 0 (PIC) to 1 (FLIP).
+	FlipBlend float64
 }
 
 // options holds the fluid simulation parameters
@@ -33,6 +68,10 @@ type options struct {
 	drawGrid         bool
 	drawDensityField bool
 	drawParticles    bool
+	injectHeat       bool
+	drawStats        bool
+	drawProjection   bool
+	ramp             []rune
 }
 
 type agent struct {
@@ -40,51 +79,96 @@ type agent struct {
 }
 
 const (
-	numOfCells         = 36 // Number of cells (not including the boundary)
+	numOfCells         = 36 // Number of cells along the shorter grid axis (not including the boundary)
 	particleTimeToLive = 8
 	maxNumberOfAgents  = 5
 	distanceThreshold  = 80
 	tickerResetTime    = 4
-	minNumOfParticles  = 30
-	maxNumOfParticles  = 60
 
 	canvasWidth  = 640
 	canvasHeight = 480
+
+	// densityScale is the density value mapped to the last character of the ramp.
+	densityScale = 10.0
+
+	// heatAmount is the heat injected together with the density.
+	heatAmount = 25.0
+
+	// emitterRadius is the radius of the mouse and the face emitters in cells.
+	emitterRadius = 2.0
+	// emitterDensity and emitterHeat are the density and the heat emitted into
+	// the center of the mouse and the face emitters.
+	emitterDensity = 16.0
+	emitterHeat    = 8.0
+
+	// maxParticles is the maximum number of the particles on the screen.
+	maxParticles = 3000
+	// particleRate is the number of the particles spawned in a second of real time while the mouse moves.
+	particleRate = 2000
+	// particleSpread is the size of the area the particles are spawned in, relative to the screen.
+	particleSpread = 0.06
+	// heavyParticleMass is the mass of the heavy particles, which push the fluid and settle under gravity.
+	heavyParticleMass = 1
+
+	// tunnelSpeed is the inflow velocity of the wind tunnel preset.
+	tunnelSpeed = 0.02
+	// sceneDensity and sceneSpeed are the emission rates of the emitters of the scene presets.
+	sceneDensity = 2.0
+	sceneSpeed   = 0.01
+
+	// timeScale is the simulation time elapsing in a second of real time.
+	timeScale = 10.0
+	// maxFrameTime limits the real time simulated in a frame, so the simulation doesn't jump after a stall.
+	maxFrameTime = 0.1
+
+	// frameOpsQueue is the number of the operations queued up for running between two frames.
+	frameOpsQueue = 64
+
+	// DefaultRamp is the character ramp used for rendering the density field.
+	DefaultRamp = " .:-=+*#%@"
+	// DefaultFlipBlend is the PIC/FLIP blend of the FLIP solver.
+	DefaultFlipBlend = fluid.DefaultFlipBlend
 )
 
 var (
-	rnd      *rand.Rand
 	lastTime time.Time
 
 	isMouseDown bool
 	isTabDown   bool
+	isWallMode  bool
+	wallType    = fluid.ObstacleNoSlip
 	oldMouseX   int
 	oldMouseY   int
+	oldFaceX    int
+	oldFaceY    int
+	brush       = leftButtonDye
 
 	termWidth  int
 	termHeight int
 	cellSize   int
-	particles  []*fluid.Particle
+	gridWidth  int
+	gridHeight int
 	agents     []agent
 
-	numOfParticles = 50
-
 	scanner *bufio.Scanner
 )
 
+// heavyParticles is the coupling of the heavy particles with the fluid. The gravity is given
+// in screens per squared unit of the simulation time.
+var heavyParticles = &fluid.Coupling{Drag: 0.5, GravityY: 0.01}
+
 var (
 	termStyle  = tcell.StyleDefault.Foreground(tcell.ColorFloralWhite).Background(tcell.NewRGBColor(0, 23, 31))
 	agentStyle = tcell.StyleDefault.Foreground(tcell.ColorYellow).Background(tcell.NewRGBColor(0, 23, 31)).Dim(true)
 	gridStyle  = tcell.StyleDefault.Foreground(tcell.ColorDimGray).Background(tcell.NewRGBColor(0, 23, 31)).Dim(true)
+	wallStyle  = tcell.StyleDefault.Foreground(tcell.ColorSlateGray).Background(tcell.NewRGBColor(0, 23, 31))
+	fluidStyle = tcell.StyleDefault.Foreground(tcell.ColorLightCyan).Background(tcell.NewRGBColor(0, 23, 31))
 )
 
-func init() {
-	rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
-}
-
 // New creates a new terminal.
-func New() *Terminal {
+func New(p *Params) *Terminal {
 	t := new(Terminal)
+	t.params = p
 	return t
 }
 
@@ -95,13 +179,27 @@ func (t *Terminal) Init() *Terminal {
 		drawGrid:         false,
 		drawDensityField: true,
 		drawParticles:    true,
+		injectHeat:       true,
+	}
+	var ramp string
+	if t.params != nil {
+		ramp = t.params.Ramp
+	}
+	if t.opts.ramp, err = densityRamp(ramp); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
 	}
 
 	lastTime = time.Now()
 	isMouseDown = false
 	oldMouseX = 0
 	oldMouseY = 0
-	particles = make([]*fluid.Particle, 0)
+	// The particles live in the simulation time, so their lifetime and rate are scaled.
+	t.ps = fluid.NewParticleSystem(maxParticles, time.Now().UnixNano())
+	t.mouseParticles = &fluid.ParticleEmitter{Spread: particleSpread, Lifetime: particleTimeToLive * timeScale, Size: 1}
+	t.faceParticles = &fluid.ParticleEmitter{Spread: particleSpread, Lifetime: particleTimeToLive * timeScale, Size: 1, Color: faceDye}
+	t.ps.AddEmitter(t.mouseParticles)
+	t.ps.AddEmitter(t.faceParticles)
 
 	t.screen, err = tcell.NewScreen()
 	if err != nil {
@@ -115,16 +213,63 @@ func (t *Terminal) Init() *Terminal {
 		fmt.Fprintf(os.Stderr, "%v\n", e)
 		os.Exit(1)
 	}
+	t.colors = detectColorMode(t.screen)
 	t.screen.SetStyle(termStyle)
 	t.screen.EnableMouse()
 	t.screen.Clear()
 
 	termWidth, termHeight = t.screen.Size()
-	cellSize = termWidth / numOfCells
-
-	t.fs = fluid.NewSolver(numOfCells)
+	if t.params != nil && t.params.ThreeD {
+		return t.initVolume()
+	}
+	gridWidth, gridHeight = gridSize(termWidth, termHeight)
+	cellSize = termWidth / gridWidth
+
+	cfg := fluid.DefaultConfig()
+	cfg.DyeChannels = dyeChannels
+	if t.params != nil {
+		if cfg.Edges, err = boundaryPreset(t.params.Boundary); err != nil {
+			t.screen.Fini()
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		if cfg.Advection, err = advectionScheme(t.params.Advection); err != nil {
+			t.screen.Fini()
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+	if t.fs, err = fluid.NewSolverConfig(gridWidth, gridHeight, cfg); err != nil {
+		t.screen.Fini()
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
 	t.fs.ResetVelocity()
 
+	// The mouse and the detected face move their own emitters around.
+	t.mouse = &fluid.Emitter{Radius: emitterRadius, Falloff: fluid.FalloffGaussian}
+	t.face = &fluid.Emitter{Radius: emitterRadius, Falloff: fluid.FalloffGaussian, Dye: faceDye[:]}
+	t.fs.AddEmitter(t.mouse)
+	t.fs.AddEmitter(t.face)
+
+	var scene []*fluid.Emitter
+	if t.params != nil {
+		scene, err = scenePreset(t.params.Scene, gridWidth, gridHeight)
+	}
+	for _, e := range scene {
+		if err == nil {
+			err = t.fs.AddEmitter(e)
+		}
+	}
+	if err == nil && t.params != nil {
+		err = t.initFlip(t.params.Solver, t.params.FlipBlend)
+	}
+	if err != nil {
+		t.screen.Fini()
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
 	return t
 }
 
@@ -142,6 +287,14 @@ func (t *Terminal) Render() {
 	quit := make(chan struct{})
 	tcpConnData := make(chan string)
 
+	// The snapshots, the grid resizing and the wall drawing run between two frames, while the solver is not running.
+	// All the operations queued up during a frame are run before the next one.
+	frameOps := make(chan func(), frameOpsQueue)
+	var autosave <-chan time.Time
+	if t.params != nil && t.params.Autosave > 0 {
+		autosave = time.NewTicker(t.params.Autosave).C
+	}
+
 	// Open TCP connection.
 	l, err := net.Listen("tcp", "localhost:6000")
 	if err != nil {
@@ -160,6 +313,9 @@ func (t *Terminal) Render() {
 			// Handle the connection in a new goroutine.
 			// Multiple connections may be served concurrently.
 			go func(c net.Conn) {
+				// Shut down the connection once the reading fails.
+				defer c.Close()
+
 				reader := bufio.NewReader(c)
 				for {
 					data, err := reader.ReadString('\n')
@@ -169,16 +325,17 @@ func (t *Terminal) Render() {
 					}
 					tcpConnData <- string(data)
 				}
-				// Shut down the connection.
-				c.Close()
 			}(conn)
 
 			ev := t.screen.PollEvent()
 
 			switch ev := ev.(type) {
 			case *tcell.EventResize:
-				termWidth, termHeight = t.screen.Size()
+				w, h := t.screen.Size()
 				t.screen.Sync()
+				frameOps <- func() {
+					t.resize(w, h)
+				}
 			case *tcell.EventKey:
 				if ev.Key() == tcell.KeyEscape {
 					// We received an interrupt signal, shut down.
@@ -192,18 +349,79 @@ func (t *Terminal) Render() {
 				if ev.Key() == tcell.KeyCtrlD {
 					t.opts.drawGrid = !t.opts.drawGrid
 				}
+				if ev.Key() == tcell.KeyCtrlS {
+					frameOps <- func() {
+						setStatus("snapshot saved to "+t.snapshotPath(), t.saveSnapshot())
+					}
+				}
+				if ev.Key() == tcell.KeyCtrlO {
+					frameOps <- func() {
+						setStatus("snapshot loaded from "+t.snapshotPath(), t.loadSnapshot())
+					}
+				}
 				if ev.Key() == tcell.KeyTAB && isMouseDown {
 					isTabDown = true
 				}
+				if ev.Key() == tcell.KeyRune {
+					switch ev.Rune() {
+					case 'w':
+						// The walls are only supported by the 2D solver, which is checked between the frames.
+						isWallMode = !isWallMode
+					case 's':
+						if wallType == fluid.ObstacleNoSlip {
+							wallType = fluid.ObstacleFreeSlip
+						} else {
+							wallType = fluid.ObstacleNoSlip
+						}
+					case 'c':
+						frameOps <- func() {
+							if t.fs != nil {
+								t.fs.ClearObstacles()
+							}
+						}
+					case 'h':
+						frameOps <- func() { t.opts.injectHeat = !t.opts.injectHeat }
+					case 'i':
+						frameOps <- func() { t.opts.drawStats = !t.opts.drawStats }
+					case 'm':
+						frameOps <- t.toggleHeavyParticles
+					case '[', ']', 'p':
+						key := ev.Rune()
+						frameOps <- func() { t.onVolumeKey(key) }
+					}
+				}
 			case *tcell.EventMouse:
 				mx, my = ev.Position()
-				t.onMouseMove(mx, my)
-
+				// In the wall mode the walls are drawn with the left button and erased with the right one,
+				// otherwise the emitters follow the mouse. Both are changed between two frames, while the
+				// solver is not running, with the input state of this event.
+				wall, o, paint := isWallMode, wallType, true
 				switch ev.Buttons() {
 				case tcell.Button1:
+				case tcell.Button3:
+					o = fluid.ObstacleNone
+				default:
+					paint = false
+				}
+				in := mouseInput{x: mx, y: my, down: isMouseDown, tab: isTabDown, brush: brush}
+				frameOps <- func() {
+					if !wall || t.fs == nil {
+						t.onMouseMove(in)
+						return
+					}
+					if paint {
+						t.paintWall(in.x, in.y, o)
+					}
+					oldMouseX, oldMouseY = in.x, in.y
+				}
+
+				switch ev.Buttons() {
+				case tcell.Button1, tcell.Button3:
 					isMouseDown = true
-					if numOfParticles < maxNumOfParticles {
-						numOfParticles++
+					if ev.Buttons() == tcell.Button1 {
+						brush = leftButtonDye
+					} else {
+						brush = rightButtonDye
 					}
 				case tcell.ButtonNone:
 					isMouseDown = false
@@ -229,14 +447,20 @@ loop:
 					curx, cury = det.X, det.Y
 				}
 				dx, dy = math.Abs(float64(det.X-curx)), math.Abs(float64(det.Y-cury))
-				if int(dx) > distanceThreshold || int(dy) > distanceThreshold {
-					isMouseDown = true
-				}
+				moved := int(dx) > distanceThreshold || int(dy) > distanceThreshold
+
 				posX := int((float64(termWidth) / float64(canvasWidth)) * float64(det.X))
 				posY := int((float64(termHeight) / float64(canvasHeight)) * float64(det.Y))
 
-				t.onMouseMove(posX, posY)
+				t.onFaceMove(posX, posY, moved)
 			}
+		case op := <-frameOps:
+			op()
+			for n := len(frameOps); n > 0; n-- {
+				(<-frameOps)()
+			}
+		case <-autosave:
+			setStatus("autosaved to "+t.snapshotPath(), t.saveSnapshot())
 		case <-time.After(time.Millisecond * 10):
 		case <-tick:
 			start = time.Now()
@@ -255,13 +479,38 @@ loop:
 	t.screen.Fini()
 }
 
-func (t *Terminal) onMouseMove(mouseX, mouseY int) {
-	// Find the cell below the mouse
-	i := int(math.Abs(float64(mouseX)/float64(termWidth))*numOfCells) + 1
-	j := int(math.Abs(float64(mouseY)/float64(termHeight))*numOfCells) + 1
+// cellAt returns the fluid cell below the terminal cell {x, y}.
+func cellAt(x, y int) (i, j int, ok bool) {
+	i = int(math.Abs(float64(x)/float64(termWidth))*float64(gridWidth)) + 1
+	j = int(math.Abs(float64(y)/float64(termHeight))*float64(gridHeight)) + 1
 
 	// Don't overflow grid bounds
-	if i > numOfCells || i < 1 || j > numOfCells || j < 1 {
+	return i, j, i <= gridWidth && i >= 1 && j <= gridHeight && j >= 1
+}
+
+// cellPos returns the position of the terminal cell {x, y} on the fluid grid, where the center
+// of the fluid cell i is at i. Unlike cellAt, it keeps the fraction of the fluid cell,
+// so the emitters move smoothly, even when a fluid cell covers several terminal cells.
+func cellPos(x, y int) (px, py float64) {
+	px = (float64(x)+0.5)/float64(termWidth)*float64(gridWidth) + 0.5
+	py = (float64(y)+0.5)/float64(termHeight)*float64(gridHeight) + 0.5
+	return px, py
+}
+
+// mouseInput is the state of the mouse and the brush, recorded by the event handler
+// and applied to the emitters between two frames.
+type mouseInput struct {
+	x, y  int
+	down  bool
+	tab   bool
+	brush [dyeChannels]float64
+}
+
+func (t *Terminal) onMouseMove(in mouseInput) {
+	mouseX, mouseY := in.x, in.y
+	// Find the cell below the mouse
+	i, j, ok := cellAt(mouseX, mouseY)
+	if !ok {
 		return
 	}
 
@@ -269,42 +518,19 @@ func (t *Terminal) onMouseMove(mouseX, mouseY int) {
 	du := float64(mouseX-oldMouseX) * 1.5
 	dv := float64(mouseY-oldMouseY) * 1.5
 
-	// Add the mouse velocity to cells above, below, to the left, and to the right as well.
-	t.fs.SetCell("uOld", i, j, du)
-	t.fs.SetCell("vOld", i, j, dv)
-
-	t.fs.SetCell("uOld", i+1, j, du)
-	t.fs.SetCell("vOld", i+1, j, dv)
-
-	t.fs.SetCell("uOld", i-1, j, du)
-	t.fs.SetCell("vOld", i-1, j, dv)
-
-	t.fs.SetCell("uOld", i, j+1, du)
-	t.fs.SetCell("vOld", i, j+1, dv)
-
-	t.fs.SetCell("uOld", i, j-1, du)
-	t.fs.SetCell("vOld", i, j-1, dv)
-
-	if isMouseDown {
-		// Add density to the cell below the mouse
-		t.fs.SetCell("dOld", i, j, 50)
+	if t.fs3 != nil {
+		t.injectVolume(i, j, du, dv, in.down)
+	} else {
+		px, py := cellPos(mouseX, mouseY)
+		t.moveEmitter(t.mouse, px, py, du, dv, in.down)
+		t.mouse.Dye = in.brush[:]
 	}
 
-	if isMouseDown && t.opts.drawParticles {
-		for i := 0; i < numOfParticles; i++ {
-			p := fluid.NewParticle(
-				float64(mouseX)+random(rnd, -10, 10),
-				float64(mouseY)+random(rnd, -10, 10),
-			)
-			p.SetVy(du)
-			p.SetVy(dv)
-
-			particles = append(particles, p)
-		}
-	}
+	t.moveParticleEmitter(t.mouseParticles, mouseX, mouseY, du, dv, in.down)
+	t.mouseParticles.Color = in.brush
 
 	// draw the fluid agents in case the tab key is pressed.
-	if isTabDown {
+	if in.tab {
 		if i, ok := t.isAgentActive(agents, mouseX, mouseY); ok && i != -1 {
 			// remove agent
 			agents = append(agents[:i], agents[i+1:]...)
@@ -321,70 +547,266 @@ func (t *Terminal) onMouseMove(mouseX, mouseY int) {
 	oldMouseY = mouseY
 }
 
+// onFaceMove moves the face emitter to the face detected at the terminal cell {x, y}.
+// The face only emits smoke when it has moved far enough, as defined by {emit}.
+func (t *Terminal) onFaceMove(x, y int, emit bool) {
+	i, j, ok := cellAt(x, y)
+	if !ok {
+		return
+	}
+
+	du := float64(x-oldFaceX) * 1.5
+	dv := float64(y-oldFaceY) * 1.5
+
+	if t.fs3 != nil {
+		t.injectVolume(i, j, du, dv, emit)
+	} else {
+		px, py := cellPos(x, y)
+		t.moveEmitter(t.face, px, py, du, dv, emit)
+	}
+
+	t.moveParticleEmitter(t.faceParticles, x, y, du, dv, emit)
+	oldFaceX, oldFaceY = x, y
+}
+
+// moveEmitter moves the emitter {e} to the grid position {px, py}, where it emits the velocity {du, dv}
+// on the next step. The density, the dye and the heat are only emitted if {emit} is set.
+func (t *Terminal) moveEmitter(e *fluid.Emitter, px, py, du, dv float64, emit bool) {
+	e.X, e.Y = px, py
+	e.DirX, e.DirY = du, dv
+	e.Speed = math.Hypot(du, dv)
+	e.Density, e.Heat = 0, 0
+	if emit {
+		e.Density = emitterDensity
+		// Hot smoke rises, while the cold one sinks under its own weight.
+		if t.opts.injectHeat {
+			e.Heat = emitterHeat
+		}
+	}
+}
+
+// stopEmitters stops the emission of the mouse and the face emitters until they move again.
+func (t *Terminal) stopEmitters() {
+	for _, e := range []*fluid.Emitter{t.mouse, t.face} {
+		e.Speed, e.Density, e.Heat = 0, 0, 0
+	}
+	t.mouseParticles.Rate, t.faceParticles.Rate = 0, 0
+}
+
+// toggleHeavyParticles switches the new particles between the passive tracers of the flow
+// and the heavy particles, which are coupled with the fluid and fall down like sand.
+func (t *Terminal) toggleHeavyParticles() {
+	var (
+		c    *fluid.Coupling
+		mass float64
+	)
+	if t.ps.Coupling() == nil {
+		c, mass = heavyParticles, heavyParticleMass
+	}
+	t.ps.SetCoupling(c)
+	t.mouseParticles.Mass, t.faceParticles.Mass = mass, mass
+}
+
+// moveParticleEmitter moves the particle emitter {e} to the terminal cell {x, y}. The emitter
+// spawns particles with the initial velocity of {du, dv} terminal cells, as long as {emit} is set.
+func (t *Terminal) moveParticleEmitter(e *fluid.ParticleEmitter, x, y int, du, dv float64, emit bool) {
+	e.X, e.Y = (float64(x)+0.5)/float64(termWidth), (float64(y)+0.5)/float64(termHeight)
+	e.VX, e.VY = du/float64(termWidth), dv/float64(termHeight)
+	e.Rate = 0
+	if emit && t.opts.drawParticles {
+		e.Rate = particleRate / timeScale
+	}
+}
+
 func (t *Terminal) update() {
 	dt := time.Now().Sub(lastTime).Seconds()
+	if t.fs3 != nil {
+		t.updateVolume(dt)
+		lastTime = time.Now()
+		return
+	}
 
-	t.fs.VelocityStep()
-	t.fs.DensityStep()
+	// The simulation runs with the real elapsed time, independently of the frame rate.
+	simDt := math.Min(dt, maxFrameTime) * timeScale
+	if t.flip != nil {
+		t.flip.Step(simDt)
+	} else {
+		t.fs.Step(simDt)
+	}
+	t.stopEmitters()
+	if err := t.fs.CheckFinite(); err != nil {
+		// Start over instead of rendering a blown up simulation.
+		if t.flip != nil {
+			t.flip.Reset()
+		} else {
+			t.fs.Reset()
+		}
+		setStatus("simulation reset, "+err.Error(), nil)
+	}
+	// The particles are moved after the reset, so they never trace a blown up velocity field.
+	t.ps.Update(simDt, t.fs)
 
 	if t.opts.drawGrid {
 		t.drawGrid()
 	}
 
-	for i := 0; i < len(particles); i++ {
-		p := particles[i]
-		p.SetAge(float64(p.GetAge()) + dt)
-
-		alpha := float64(1 - p.GetAge()/particleTimeToLive)
-		if alpha < 0.001 ||
-			p.GetAge() >= particleTimeToLive ||
-			p.GetX() <= 0.0 || p.GetX() >= float64(termWidth) ||
-			p.GetY() <= 0.0 || p.GetY() >= float64(termHeight) {
-			p.SetDeath(true)
+	if t.opts.drawDensityField {
+		if t.flip != nil {
+			t.drawFlipParticles()
 		} else {
-			x0 := int(math.Abs(float64(p.GetX())/float64(termWidth))*numOfCells) + 2
-			y0 := int(math.Abs(float64(p.GetY())/float64(termHeight))*numOfCells) + 2
+			t.drawDensityField()
+		}
+	}
+	t.drawObstacles()
 
-			p.SetVx(t.fs.GetCell("u", x0, y0) * 50)
-			p.SetVy(t.fs.GetCell("v", x0, y0) * 50)
+	t.drawParticles()
 
-			p.SetX(float64(p.GetX() + p.GetVx()))
-			p.SetY(float64(p.GetY() + p.GetVy()))
+	for i := 0; i < len(agents); i++ {
+		t.drawAgent(agents[i].x, agents[i].y)
+	}
+	if t.opts.drawStats {
+		t.drawStats()
+	}
+	t.drawStatus()
 
-			// Apply a velocity factor to the existing agents
-			for i := 0; i < len(agents); i++ {
-				x0 := int(math.Abs(float64(agents[i].x)/float64(termWidth))*numOfCells) + 2
-				y0 := int(math.Abs(float64(agents[i].y)/float64(termHeight))*numOfCells) + 2
+	lastTime = time.Now()
+}
 
-				p.SetVx(t.fs.GetCell("u", x0, y0) * 5)
-				p.SetVy(t.fs.GetCell("v", x0, y0) * 5)
+// densityRamp returns the characters of the density {ramp}, or the ones of DefaultRamp for the empty ramp.
+func densityRamp(ramp string) ([]rune, error) {
+	if ramp == "" {
+		ramp = DefaultRamp
+	}
+	if !utf8.ValidString(ramp) {
+		return nil, fmt.Errorf("invalid density ramp: %q is not valid UTF-8", ramp)
+	}
+	if utf8.RuneCountInString(ramp) < 2 {
+		return nil, fmt.Errorf("invalid density ramp: %q, it needs at least two characters", ramp)
+	}
+	return []rune(ramp), nil
+}
 
-				p.SetX(float64(p.GetX() + p.GetVx()))
-				p.SetY(float64(p.GetY() + p.GetVy()))
+// boundaryPreset returns the edge conditions of the named boundary preset.
+func boundaryPreset(name string) (fluid.Edges, error) {
+	switch name {
+	case "", "closed":
+		return fluid.Edges{}, nil
+	case "periodic":
+		periodic := fluid.Edge{Type: fluid.EdgePeriodic}
+		return fluid.Edges{Left: periodic, Right: periodic, Top: periodic, Bottom: periodic}, nil
+	case "tunnel":
+		return fluid.Edges{
+			Left:  fluid.Edge{Type: fluid.EdgeInflow, U: tunnelSpeed},
+			Right: fluid.Edge{Type: fluid.EdgeOutflow},
+		}, nil
+	}
+	return fluid.Edges{}, fmt.Errorf("unknown boundary preset: %q", name)
+}
 
-			}
-			t.screen.SetContent(int(p.GetX()), int(p.GetY()), '▄', nil, termStyle)
-		}
+// scenePreset returns the emitters of the scene preset {name}, placed on a grid of {nx} x {ny} cells.
+func scenePreset(name string, nx, ny int) ([]*fluid.Emitter, error) {
+	switch name {
+	case "", "none":
+		return nil, nil
+	case "plume":
+		// A hot smoke source at the bottom of the screen.
+		return []*fluid.Emitter{{
+			X: float64(nx) / 2, Y: float64(ny) - 2, Radius: 2, Falloff: fluid.FalloffGaussian,
+			Density: sceneDensity, Heat: sceneDensity / 2, Dye: rightButtonDye[:],
+		}}, nil
+	case "jets":
+		// Two jets of different colors, blowing against each other.
+		return []*fluid.Emitter{{
+			X: 3, Y: float64(ny) / 2, Radius: 1.5, Falloff: fluid.FalloffLinear,
+			Density: sceneDensity, Dye: leftButtonDye[:], DirX: 1, Speed: sceneSpeed,
+		}, {
+			X: float64(nx) - 2, Y: float64(ny) / 2, Radius: 1.5, Falloff: fluid.FalloffLinear,
+			Density: sceneDensity, Dye: rightButtonDye[:], DirX: -1, Speed: sceneSpeed,
+		}}, nil
+	}
+	return nil, fmt.Errorf("unknown scene preset: %q", name)
+}
 
-		if p.GetDeath() {
-			// Remove dead particles, and update the length manually
-			particles = append(particles[:i], particles[i+1:]...)
-		}
+// advectionScheme returns the named advection scheme.
+func advectionScheme(name string) (fluid.AdvectionScheme, error) {
+	switch name {
+	case "", "sl":
+		return fluid.AdvectionSemiLagrangian, nil
+	case "maccormack":
+		return fluid.AdvectionMacCormack, nil
+	case "bfecc":
+		return fluid.AdvectionBFECC, nil
 	}
+	return 0, fmt.Errorf("unknown advection scheme: %q", name)
+}
 
-	for i := 0; i < len(agents); i++ {
-		t.drawAgent(agents[i].x, agents[i].y)
+// gridSize returns the simulation grid dimensions matching the terminal aspect ratio.
+// The terminal cells are about twice as high as wide, so the shorter side of the screen
+// gets numOfCells fluid cells and the longer one is scaled up proportionally.
+// A screen without width or height is sized like a single terminal cell.
+func gridSize(width, height int) (nx, ny int) {
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
 	}
+	aspect := float64(width) / float64(2*height)
+	if aspect >= 1 {
+		return int(math.Round(numOfCells * aspect)), numOfCells
+	}
+	return numOfCells, int(math.Round(numOfCells / aspect))
+}
 
-	if !isMouseDown {
-		time.AfterFunc(time.Second, func() {
-			if numOfParticles > minNumOfParticles {
-				numOfParticles--
-			}
-		})
+// resize adapts the fluid grid to the new terminal size of {w} x {h} cells.
+// The fields of the solver are resampled, so the current flow is kept.
+func (t *Terminal) resize(w, h int) {
+	termWidth, termHeight = w, h
+	if t.fs != nil {
+		nx, ny := gridSize(w, h)
+		resize := t.fs.Resize
+		if t.flip != nil {
+			resize = t.flip.Resize
+		}
+		if err := resize(nx, ny); err != nil {
+			setStatus("", err)
+			return
+		}
+		gridWidth, gridHeight = nx, ny
 	}
+	cellSize = termWidth / gridWidth
+}
 
-	lastTime = time.Now()
+// drawParticles draws the particles with their own color. The particles are pushed
+// by the fluid velocity below the agents as well.
+func (t *Terminal) drawParticles() {
+	for _, p := range t.ps.Particles() {
+		// Apply a velocity factor to the existing agents
+		for i := 0; i < len(agents); i++ {
+			au, av := t.fs.SampleVelocity(
+				float64(agents[i].x)/float64(termWidth),
+				float64(agents[i].y)/float64(termHeight),
+			)
+			p.SetX(p.GetX() + au*5/float64(termWidth))
+			p.SetY(p.GetY() + av*5/float64(termHeight))
+		}
+
+		glyph := '▄'
+		if p.GetSize() < 1 {
+			glyph = '·'
+		}
+		x, y := int(p.GetX()*float64(termWidth)), int(p.GetY()*float64(termHeight))
+		t.screen.SetContent(x, y, glyph, nil, t.particleStyle(p))
+	}
+}
+
+// particleStyle returns the style of the particle {p}, colored by its own color.
+func (t *Terminal) particleStyle(p *fluid.Particle) tcell.Style {
+	c := p.GetColor()
+	if t.colors == colorMono || c == [3]float64{} {
+		return termStyle
+	}
+	return termStyle.Foreground(t.rgbColor(c[0], c[1], c[2]))
 }
 
 // drawGrid draws the fluid grid.
@@ -396,6 +818,85 @@ func (t *Terminal) drawGrid() {
 	}
 }
 
+// drawDensityField draws the density field of the solver, colored by the mix of the dye channels.
+func (t *Terminal) drawDensityField() {
+	var dyes [dyeChannels][]float64
+	for c := range dyes {
+		dyes[c], _ = t.fs.Dye(c)
+	}
+	t.drawDensity(t.fs.Density(), dyes)
+}
+
+// drawDensity maps the density of the fluid cell below each terminal cell to a character of the
+// intensity ramp. The density {d} and the dye channels are laid out like the fields of the 2D solver.
+func (t *Terminal) drawDensity(d []float64, dyes [dyeChannels][]float64) {
+	ramp := t.opts.ramp
+	levels := float64(len(ramp) - 1)
+
+	for x := 0; x < termWidth; x++ {
+		i := int(float64(x)/float64(termWidth)*float64(gridWidth)) + 1
+		for y := 0; y < termHeight; y++ {
+			j := int(float64(y)/float64(termHeight)*float64(gridHeight)) + 1
+
+			k := i + (gridWidth+2)*j
+
+			level := int(d[k] / densityScale * levels)
+			if level <= 0 {
+				// Keep the underlying content (e.g. the grid) for the empty cells.
+				continue
+			}
+			if level > len(ramp)-1 {
+				level = len(ramp) - 1
+			}
+			t.screen.SetContent(x, y, ramp[level], nil, t.dyeStyle(dyes, k))
+		}
+	}
+}
+
+// dyeStyle returns the style of the fluid cell at index {k}. The dye channels are normalized
+// by the strongest one, so the ramp character alone defines the intensity.
+func (t *Terminal) dyeStyle(dyes [dyeChannels][]float64, k int) tcell.Style {
+	if t.colors == colorMono || dyes[0] == nil {
+		return fluidStyle
+	}
+	var rgb [dyeChannels]float64
+	max := 0.0
+	for c := range dyes {
+		rgb[c] = dyes[c][k]
+		max = math.Max(max, rgb[c])
+	}
+	if max < 1e-6 {
+		return fluidStyle
+	}
+	return fluidStyle.Foreground(t.rgbColor(rgb[0]/max, rgb[1]/max, rgb[2]/max))
+}
+
+// drawObstacles draws the terminal cells covered by the solid cells of the fluid grid.
+func (t *Terminal) drawObstacles() {
+	for x := 0; x < termWidth; x++ {
+		i := int(float64(x)/float64(termWidth)*float64(gridWidth)) + 1
+		for y := 0; y < termHeight; y++ {
+			j := int(float64(y)/float64(termHeight)*float64(gridHeight)) + 1
+
+			switch t.fs.ObstacleAt(i, j) {
+			case fluid.ObstacleNoSlip:
+				t.screen.SetContent(x, y, tcell.RuneBlock, nil, wallStyle)
+			case fluid.ObstacleFreeSlip:
+				t.screen.SetContent(x, y, tcell.RuneCkBoard, nil, wallStyle)
+			}
+		}
+	}
+}
+
+// paintWall sets the obstacle type of the fluid cell below the mouse.
+func (t *Terminal) paintWall(mouseX, mouseY int, o fluid.Obstacle) {
+	i := int(math.Abs(float64(mouseX)/float64(termWidth))*float64(gridWidth)) + 1
+	j := int(math.Abs(float64(mouseY)/float64(termHeight))*float64(gridHeight)) + 1
+
+	// The cells outside of the grid are ignored by the solver.
+	t.fs.SetObstacle(i, j, o)
+}
+
 // drawAgent draws an agent at {x, y} position.
 func (t *Terminal) drawAgent(mx, my int) {
 	t.screen.SetContent(mx, my, tcell.RuneBlock, nil, agentStyle)
@@ -411,9 +912,13 @@ func (t *Terminal) isAgentActive(agents []agent, x, y int) (int, bool) {
 	return -1, false
 }
 
-// random generates a random float number between min and max.
-func random(rnd *rand.Rand, min, max int) float64 {
-	return float64(rnd.Intn(max-min) + min)
+// drawStats draws the diagnostics of the simulation in the top left corner.
+func (t *Terminal) drawStats() {
+	st := t.fs.Stats()
+	debug(t.screen, 1, 0, termStyle, fmt.Sprintf("div %.2e  mass %.1f  energy %.2e  enstrophy %.2e  speed %.3f",
+		st.MaxDivergence, st.Mass, st.KineticEnergy, st.Enstrophy, st.MaxSpeed))
+	debug(t.screen, 1, 1, termStyle, fmt.Sprintf("pressure %d iterations, residual %.2e",
+		st.PressureIterations, st.PressureResidual))
 }
 
 // debug is a helper method for printing out various information straight in the terminal.
@@ -0,0 +1,93 @@
+package terminal
+
+import (
+	"fmt"
+	"math"
+	"os"
+
+	fluid "github.com/esimov/ascii-fluid/fluid-solver"
+)
+
+// volumeCells is the number of the fluid cells along each axis of the 3D solver.
+const volumeCells = 32
+
+// sliceZ is the z-slice of the volume shown on the screen.
+var sliceZ int
+
+// initVolume creates the 3D fluid solver. The mouse interacts with the shown z-slice of the volume.
+func (t *Terminal) initVolume() *Terminal {
+	var err error
+
+	gridWidth, gridHeight = volumeCells, volumeCells
+	cellSize = termWidth / gridWidth
+	if t.fs3, err = fluid.NewSolver3DConfig(volumeCells, fluid.DefaultConfig()); err != nil {
+		t.screen.Fini()
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	sliceZ = volumeCells / 2
+	// The particles are moved by the 2D velocity field only.
+	t.opts.drawParticles = false
+
+	return t
+}
+
+// injectVolume adds the velocity {du, dv} to the cell (i, j) of the shown z-slice and its neighbours.
+// The density and the heat are only added if {emit} is set.
+func (t *Terminal) injectVolume(i, j int, du, dv float64, emit bool) {
+	for _, c := range [][2]int{{0, 0}, {1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+		t.fs3.AddVelocity(i+c[0], j+c[1], sliceZ, du, dv, 0)
+	}
+	if emit {
+		t.fs3.AddDensity(i, j, sliceZ, 50)
+		if t.opts.injectHeat {
+			t.fs3.AddHeat(i, j, sliceZ, heatAmount)
+		}
+	}
+}
+
+// onVolumeKey moves the shown z-slice and toggles the projection of the volume.
+func (t *Terminal) onVolumeKey(key rune) {
+	if t.fs3 == nil {
+		return
+	}
+	switch key {
+	case '[':
+		if sliceZ > 1 {
+			sliceZ--
+		}
+	case ']':
+		if sliceZ < t.fs3.Size() {
+			sliceZ++
+		}
+	case 'p':
+		t.opts.drawProjection = !t.opts.drawProjection
+	}
+}
+
+// updateVolume advances the 3D simulation by {dt} seconds and draws either the shown z-slice
+// or the maximum intensity projection of the density along the z axis.
+func (t *Terminal) updateVolume(dt float64) {
+	t.fs3.Step(math.Min(dt, maxFrameTime) * timeScale)
+
+	if t.opts.drawGrid {
+		t.drawGrid()
+	}
+
+	var (
+		d     []float64
+		label string
+		dyes  [dyeChannels][]float64
+	)
+	if t.opts.drawProjection {
+		d, label = t.fs3.DensityProjection(), "projection"
+	} else {
+		d, _ = t.fs3.DensitySlice(sliceZ)
+		label = fmt.Sprintf("slice %d/%d", sliceZ, t.fs3.Size())
+	}
+	if t.opts.drawDensityField {
+		t.drawDensity(d, dyes)
+	}
+	debug(t.screen, 1, 0, termStyle, label)
+	t.drawStatus()
+}